@@ -0,0 +1,238 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// Aleph sequential is a line based format, one field per line:
+//
+//	000000001 LDR   L 00000nam^a2200000^a^4500
+//	000000001 001   L 000000001
+//	000000001 24510 L $$aTitle$$bSubtitle
+//
+// The first nine characters are the document number, followed by tag,
+// indicators and the letter L. Blanks in the leader and in control fields
+// are written as ^, subfields are introduced by $$.
+
+// alephBlanks converts blanks to ^ and back
+var (
+	alephToBlank = strings.NewReplacer("^", " ")
+	blankToAleph = strings.NewReplacer(" ", "^")
+)
+
+// alephDocNumber returns the value of 001, if it is a number of up to nine
+// digits, otherwise the sequence number
+func alephDocNumber(record *marc22.Record, seq int64) string {
+	for _, f := range record.GetControlFields("001") {
+		id := strings.TrimSpace(f.Data)
+		if len(id) == 0 || len(id) > 9 || strings.Trim(id, "0123456789") != "" {
+			break
+		}
+		return strings.Repeat("0", 9-len(id)) + id
+	}
+	return fmt.Sprintf("%09d", seq)
+}
+
+// MarshalAleph serializes a record to Aleph sequential lines with the given
+// document number.
+func MarshalAleph(record *marc22.Record, docnum string) ([]byte, error) {
+	if len(docnum) != 9 {
+		return nil, fmt.Errorf("invalid document number: %q", docnum)
+	}
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s LDR   L %s\n", docnum, blankToAleph.Replace(string(leader)))
+	for _, field := range record.ControlFields {
+		fmt.Fprintf(&buf, "%s %s   L %s\n", docnum, field.Tag, blankToAleph.Replace(field.Data))
+	}
+	for _, field := range record.DataFields {
+		ind1, err := indicator(field.Ind1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		ind2, err := indicator(field.Ind2)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		fmt.Fprintf(&buf, "%s %s%c%c L ", docnum, field.Tag, ind1, ind2)
+		for _, subfield := range field.SubFields {
+			if subfield.Code != "" {
+				buf.WriteString("$$" + subfield.Code)
+			}
+			buf.WriteString(subfield.Value)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// AlephWriter writes records in Aleph sequential format. The document number
+// is taken from 001, if it is numeric, otherwise records are numbered
+// consecutively, starting with 1.
+type AlephWriter struct {
+	w   io.Writer
+	seq int64
+}
+
+// NewAlephWriter returns a new writer, that writes to w.
+func NewAlephWriter(w io.Writer) *AlephWriter {
+	return &AlephWriter{w: w}
+}
+
+// Write serializes a single record.
+func (w *AlephWriter) Write(record *marc22.Record) error {
+	w.seq++
+	b, err := MarshalAleph(record, alephDocNumber(record, w.seq))
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
+
+// alephLine is a single parsed line
+type alephLine struct {
+	docnum, tag, ind, data string
+}
+
+// parseAlephLine splits a line into its parts
+func parseAlephLine(line string) (alephLine, error) {
+	if len(line) < 18 || line[9] != ' ' || line[15] != ' ' || line[17] != ' ' {
+		if len(line) != 17 || line[9] != ' ' || line[15] != ' ' {
+			return alephLine{}, fmt.Errorf("%w: invalid line: %q", ErrInvalidRecord, line)
+		}
+		line += " " // empty value
+	}
+	return alephLine{docnum: line[0:9], tag: line[10:13], ind: line[13:15], data: line[18:]}, nil
+}
+
+// alephRecord creates a record from the lines of a single document
+func alephRecord(lines []alephLine) (*marc22.Record, error) {
+	record := &marc22.Record{}
+	for _, l := range lines {
+		switch {
+		case l.tag == "LDR":
+			leader := alephToBlank.Replace(l.data)
+			parsed, err := parseLeaderTemplate(leader)
+			if err != nil {
+				return nil, err
+			}
+			record.Leader, record.LeaderParsed = leader, parsed
+		case strings.HasPrefix(l.tag, "00"):
+			record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: l.tag, Data: alephToBlank.Replace(l.data)})
+		default:
+			// fields without subfields, e.g. FMT, keep the value before the
+			// first delimiter, cf. ParseRecord
+			field := marc22.DataField{Tag: l.tag, Ind1: l.ind[0:1], Ind2: l.ind[1:2]}
+			for i, chunk := range strings.Split(l.data, "$$") {
+				switch {
+				case i == 0 && chunk == "":
+					continue
+				case i == 0:
+					field.SubFields = append(field.SubFields, &marc22.SubField{Value: chunk})
+				case chunk == "":
+					return nil, fmt.Errorf("%w: %s: missing subfield code", ErrInvalidRecord, l.tag)
+				default:
+					field.SubFields = append(field.SubFields, &marc22.SubField{Code: chunk[0:1], Value: chunk[1:]})
+				}
+			}
+			record.DataFields = append(record.DataFields, field)
+		}
+	}
+	if record.LeaderParsed == nil {
+		return nil, fmt.Errorf("%w: record has no leader", ErrInvalidLeader)
+	}
+	return record, nil
+}
+
+// AlephReader reads records in Aleph sequential format from a stream.
+// Consecutive lines with the same document number form a record.
+type AlephReader struct {
+	// Filename is used in errors, if not empty.
+	Filename string
+
+	r     *bufio.Reader
+	line  int        // number of lines read
+	next  *alephLine // first line of the next record, if already read
+	index int64
+	err   error
+}
+
+// NewAlephReader returns a reader, that reads from r.
+func NewAlephReader(r io.Reader) *AlephReader {
+	return &AlephReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record or io.EOF. Invalid records are reported as
+// *RecordError and reading can continue.
+func (r *AlephReader) Next() (*marc22.Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var lines []alephLine
+	var start int
+	var lineErr error
+	if r.next != nil {
+		lines, start, r.next = append(lines, *r.next), r.line, nil
+	}
+	for {
+		s, err := r.r.ReadString('\n')
+		if err == io.EOF && s != "" {
+			err = nil
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+				return nil, err
+			}
+			if len(lines) == 0 && lineErr == nil {
+				r.err = io.EOF
+				return nil, r.err
+			}
+			break
+		}
+		r.line++
+		s = strings.TrimRight(s, "\r\n")
+		if s == "" {
+			continue
+		}
+		l, err := parseAlephLine(s)
+		if err != nil {
+			// keep the line number of the first error
+			if lineErr == nil {
+				lineErr = err
+				if len(lines) == 0 {
+					start = r.line
+				}
+			}
+			continue
+		}
+		if len(lines) > 0 && l.docnum != lines[0].docnum {
+			r.next = &l
+			break
+		}
+		if len(lines) == 0 && lineErr == nil {
+			start = r.line
+		}
+		lines = append(lines, l)
+	}
+	index := r.index
+	r.index++
+	if lineErr != nil {
+		return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Line: start, Err: lineErr}
+	}
+	record, err := alephRecord(lines)
+	if err != nil {
+		return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Line: start, Err: err}
+	}
+	return record, nil
+}
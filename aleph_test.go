@@ -0,0 +1,119 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlephRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		var data [][]byte
+		w := NewAlephWriter(&buf)
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if err := w.Write(record); err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			data = append(data, rr.Data)
+		}
+		file.Close()
+
+		ar := NewAlephReader(&buf)
+		for i := 0; ; i++ {
+			record, err := ar.Next()
+			if err == io.EOF {
+				if i != len(data) {
+					t.Errorf("%s: got %d records, want: %d", filename, i, len(data))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := Marshal(record)
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if !bytes.Equal(b, data[i]) {
+				t.Errorf("%s: record %d => %q, want: %q", filename, i, b, data[i])
+			}
+		}
+	}
+}
+
+func TestAlephReader(t *testing.T) {
+	var in = `000000001 FMT   L BK
+000000001 LDR   L 00000nam^a2200000^a^4500
+000000001 001   L 000000001
+000000001 008   L 890101s1988^^^^xx
+000000001 24510 L $$aTitle$$bSubtitle
+000000001 CAT   L $$aBATCH$$b00$$c20150101
+000000002 LDR   L 00000nam^a2200000^a^4500
+000000002 245
+000000003 001   L 000000003
+000000004 LDR   L 00000nam^a2200000^a^4500
+000000004 001   L 000000004
+`
+	var tags [][]string
+	var lines []int
+	reader := NewAlephReader(strings.NewReader(in))
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var rerr *RecordError
+			if !errors.As(err, &rerr) {
+				t.Fatal(err)
+			}
+			lines = append(lines, rerr.Line)
+			continue
+		}
+		var fields []string
+		for _, f := range record.ControlFields {
+			fields = append(fields, f.Tag+"="+f.Data)
+		}
+		for _, f := range record.DataFields {
+			fields = append(fields, f.Tag+f.Ind1+f.Ind2+"="+f.String())
+		}
+		tags = append(tags, fields)
+	}
+	want := [][]string{
+		{"001=000000001", "008=890101s1988    xx", "FMT  =FMT [  ] [() BK]",
+			"24510=245 [10] [(a) Title], [(b) Subtitle]", "CAT  =CAT [  ] [(a) BATCH], [(b) 00], [(c) 20150101]"},
+		{"001=000000004"},
+	}
+	if !reflect.DeepEqual(tags, want) {
+		t.Errorf("AlephReader: got %q, want: %q", tags, want)
+	}
+	if want := []int{7, 9}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("AlephReader: got errors at lines %v, want: %v", lines, want)
+	}
+}
@@ -0,0 +1,313 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/miku/marc22"
+)
+
+// Bulk formats for search engines, cf. BulkOptions
+const (
+	BulkElasticsearch = "es"   // Elasticsearch bulk API, newline delimited JSON
+	BulkSolr          = "solr" // Solr JSON update commands
+)
+
+// Elasticsearch bulk operations for records, that are not deleted
+const (
+	OpIndex  = "index"  // add or replace a document
+	OpCreate = "create" // add a document, fail if it exists
+)
+
+// BulkOptions describe how records are turned into bulk requests. Records
+// with status d (deleted, leader/05) result in delete actions.
+type BulkOptions struct {
+	Format     string          // BulkElasticsearch or BulkSolr
+	Index      string          // Elasticsearch index, optional, if the URL contains the index
+	OpType     string          // OpIndex (default) or OpCreate, Elasticsearch only
+	Identifier *IdentifierSpec // document id, DefaultIdentifierSpec if nil
+}
+
+// Check returns an error for unknown formats and operations.
+func (o *BulkOptions) Check() error {
+	switch o.Format {
+	case BulkElasticsearch:
+		switch o.OpType {
+		case "", OpIndex, OpCreate:
+			return nil
+		}
+		return fmt.Errorf("unknown bulk operation: %s", o.OpType)
+	case BulkSolr:
+		if o.Index != "" || o.OpType != "" {
+			return errors.New("index and operation are not supported for solr")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown bulk format: %s", o.Format)
+}
+
+// Marshal returns the bulk action for a record and its JSON document, doc.
+// For Elasticsearch, this is an action line, followed by the document, if
+// the record is not deleted. For Solr, it is a single add or delete command,
+// the document gets an additional id field; the commands are enclosed in an
+// object by ChunkWriter.
+func (o *BulkOptions) Marshal(record *marc22.Record, doc []byte) ([]byte, error) {
+	spec := o.Identifier
+	if spec == nil {
+		spec = DefaultIdentifierSpec
+	}
+	id, err := spec.Identifier(record)
+	if err != nil {
+		return nil, err
+	}
+	deleted := leaderByte(record, 5) == 'd'
+	var buf bytes.Buffer
+	switch o.Format {
+	case BulkElasticsearch:
+		op := o.OpType
+		if op == "" {
+			op = OpIndex
+		}
+		if deleted {
+			op = "delete"
+		}
+		meta := struct {
+			Index string `json:"_index,omitempty"`
+			ID    string `json:"_id"`
+		}{o.Index, id}
+		action, err := json.Marshal(map[string]interface{}{op: meta})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(action)
+		if !deleted {
+			buf.WriteString("\n")
+			buf.Write(doc)
+		}
+	case BulkSolr:
+		b, err := json.Marshal(id)
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			buf.WriteString(`"delete":{"id":`)
+			buf.Write(b)
+			buf.WriteString(`}`)
+			break
+		}
+		doc = bytes.TrimSpace(doc)
+		if len(doc) < 2 || doc[0] != '{' {
+			return nil, fmt.Errorf("solr document is not an object: %.20q", doc)
+		}
+		buf.WriteString(`"add":{"doc":{"id":`)
+		buf.Write(b)
+		if rest := bytes.TrimSpace(doc[1:]); !bytes.Equal(rest, []byte("}")) {
+			buf.WriteString(",")
+		}
+		buf.Write(doc[1:])
+		buf.WriteString(`}`)
+	default:
+		return nil, fmt.Errorf("unknown bulk format: %s", o.Format)
+	}
+	return buf.Bytes(), nil
+}
+
+// ChunkWriter writes serialized records, one per line, in chunks of at most
+// Size records, e.g. to split bulk requests into files. For BulkSolr, the
+// commands of a chunk are separated by commas and enclosed in braces, so
+// that each chunk is a single JSON object.
+type ChunkWriter struct {
+	Format string // empty or BulkElasticsearch for lines, BulkSolr for an object
+	Size   int    // records per chunk, 0 for a single chunk
+
+	next  func(chunk int) (io.WriteCloser, error)
+	w     io.WriteCloser
+	chunk int // number of the next chunk
+	count int // records in the current chunk
+}
+
+// NewChunkWriter returns a writer, that calls next to get the destination of
+// each chunk; it is closed after the last record of the chunk.
+func NewChunkWriter(format string, size int, next func(chunk int) (io.WriteCloser, error)) *ChunkWriter {
+	return &ChunkWriter{Format: format, Size: size, next: next}
+}
+
+// Write writes a single record.
+func (w *ChunkWriter) Write(b []byte) error {
+	if w.w != nil && w.Size > 0 && w.count == w.Size {
+		if err := w.closeChunk(); err != nil {
+			return err
+		}
+	}
+	sep := ",\n"
+	if w.w == nil {
+		dst, err := w.next(w.chunk)
+		if err != nil {
+			return err
+		}
+		w.w, w.count = dst, 0
+		w.chunk++
+		sep = "{\n"
+	}
+	if w.Format == BulkSolr {
+		if _, err := io.WriteString(w.w, sep); err != nil {
+			return err
+		}
+		_, err := w.w.Write(b)
+		w.count++
+		return err
+	}
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, "\n")
+	w.count++
+	return err
+}
+
+// closeChunk ends the current chunk
+func (w *ChunkWriter) closeChunk() error {
+	if w.Format == BulkSolr {
+		if _, err := io.WriteString(w.w, "\n}\n"); err != nil {
+			w.w.Close()
+			w.w = nil
+			return err
+		}
+	}
+	err := w.w.Close()
+	w.w = nil
+	return err
+}
+
+// Close ends the last chunk. Without any records, no chunk is written.
+func (w *ChunkWriter) Close() error {
+	if w.w == nil {
+		return nil
+	}
+	return w.closeChunk()
+}
+
+// ChunkFanInWriter writes the channel content to the chunk writer, closes it
+// and sends the first error, if any, on done. After an error, the current
+// chunk is closed, nothing more is written, stop is closed, so that producers
+// can quit early, and the channel is drained, until the producers close it.
+func ChunkFanInWriter(writer *ChunkWriter, in chan []byte, done chan error, stop chan struct{}) {
+	var err error
+	for b := range in {
+		if err != nil {
+			continue
+		}
+		if err = writer.Write(b); err != nil {
+			writer.Close()
+			if stop != nil {
+				close(stop)
+			}
+		}
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	done <- err
+}
+
+// bufferedFile flushes its buffer on close
+type bufferedFile struct {
+	*bufio.Writer
+	file *os.File
+}
+
+func (f *bufferedFile) Close() error {
+	if err := f.Flush(); err != nil {
+		f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// ChunkFiles returns a function for NewChunkWriter, that creates the files
+// prefix00000000, prefix00000001 and so on, as marcsplit does.
+func ChunkFiles(prefix string) func(chunk int) (io.WriteCloser, error) {
+	return func(chunk int) (io.WriteCloser, error) {
+		file, err := createSplitFile("", prefix, int64(chunk))
+		if err != nil {
+			return nil, err
+		}
+		return &bufferedFile{Writer: bufio.NewWriter(file), file: file}, nil
+	}
+}
+
+// ChunkPoster returns a function for NewChunkWriter, that sends each chunk
+// in a POST request to url, e.g. http://localhost:9200/_bulk or
+// http://localhost:8983/solr/biblio/update. A status other than 2XX is an
+// error, as is an Elasticsearch response, that reports failed items.
+func ChunkPoster(client *http.Client, url, format string) func(chunk int) (io.WriteCloser, error) {
+	return func(chunk int) (io.WriteCloser, error) {
+		return &chunkRequest{client: client, url: url, format: format, chunk: chunk}, nil
+	}
+}
+
+// chunkRequest collects a chunk and posts it on close
+type chunkRequest struct {
+	bytes.Buffer
+	client *http.Client
+	url    string
+	format string
+	chunk  int
+}
+
+func (r *chunkRequest) Close() error {
+	contentType := "application/json"
+	if r.format == BulkElasticsearch {
+		contentType = "application/x-ndjson"
+	}
+	resp, err := r.client.Post(r.url, contentType, &r.Buffer)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("chunk %d: %s: %s: %.200s", r.chunk, r.url, resp.Status, body)
+	}
+	if r.format == BulkElasticsearch {
+		return bulkResponseError(r.chunk, body)
+	}
+	return nil
+}
+
+// bulkResponseError returns an error for the first failed item of an
+// Elasticsearch bulk response, if any
+func bulkResponseError(chunk int, body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			ID     string          `json:"_id"`
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("chunk %d: invalid bulk response: %v", chunk, err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	for _, item := range resp.Items {
+		for op, result := range item {
+			if result.Error != nil {
+				return fmt.Errorf("chunk %d: %s %s: status %d: %s", chunk, op, result.ID, result.Status, result.Error)
+			}
+		}
+	}
+	return fmt.Errorf("chunk %d: bulk request failed", chunk)
+}
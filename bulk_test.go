@@ -0,0 +1,301 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+// bulkTestRecord returns a record with the given 001 and leader status
+func bulkTestRecord(id string, status byte) *marc22.Record {
+	leader := []byte("00000nam a2200000 a 4500")
+	leader[5] = status
+	return &marc22.Record{
+		Leader:        string(leader),
+		ControlFields: []marc22.ControlField{{Tag: "001", Data: id}},
+	}
+}
+
+func TestBulkOptionsMarshal(t *testing.T) {
+	var tests = []struct {
+		options BulkOptions
+		record  *marc22.Record
+		doc     string
+		out     string
+		err     error
+	}{
+		{BulkOptions{Format: BulkElasticsearch}, bulkTestRecord("1", 'c'), `{"a":1}`,
+			`{"index":{"_id":"1"}}` + "\n" + `{"a":1}`, nil},
+		{BulkOptions{Format: BulkElasticsearch, Index: "biblio", OpType: OpCreate}, bulkTestRecord("1", 'n'), `{"a":1}`,
+			`{"create":{"_index":"biblio","_id":"1"}}` + "\n" + `{"a":1}`, nil},
+		{BulkOptions{Format: BulkElasticsearch, Index: "biblio"}, bulkTestRecord("1", 'd'), `{"a":1}`,
+			`{"delete":{"_index":"biblio","_id":"1"}}`, nil},
+		{BulkOptions{Format: BulkSolr}, bulkTestRecord("x\"1", 'c'), `{"a":1}`,
+			`"add":{"doc":{"id":"x\"1","a":1}}`, nil},
+		{BulkOptions{Format: BulkSolr}, bulkTestRecord("1", 'c'), `{}`,
+			`"add":{"doc":{"id":"1"}}`, nil},
+		{BulkOptions{Format: BulkSolr}, bulkTestRecord("1", 'd'), `{"a":1}`,
+			`"delete":{"id":"1"}`, nil},
+		{BulkOptions{Format: BulkSolr, Identifier: &IdentifierSpec{Fields: []IdentifierField{{Tag: "003"}}}},
+			bulkTestRecord("1", 'c'), `{}`, "", ErrMissingIdentifier},
+	}
+	for _, tt := range tests {
+		b, err := tt.options.Marshal(tt.record, []byte(tt.doc))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Marshal(%+v) => %v, want: %v", tt.options, err, tt.err)
+		}
+		if string(b) != tt.out {
+			t.Errorf("Marshal(%+v) => %s, want: %s", tt.options, b, tt.out)
+		}
+	}
+}
+
+func TestBulkOptionsCheck(t *testing.T) {
+	var tests = []struct {
+		options BulkOptions
+		ok      bool
+	}{
+		{BulkOptions{Format: BulkElasticsearch}, true},
+		{BulkOptions{Format: BulkElasticsearch, OpType: OpCreate}, true},
+		{BulkOptions{Format: BulkElasticsearch, OpType: "update"}, false},
+		{BulkOptions{Format: BulkSolr}, true},
+		{BulkOptions{Format: BulkSolr, Index: "biblio"}, false},
+		{BulkOptions{Format: "opensearch"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.options.Check(); (err == nil) != tt.ok {
+			t.Errorf("Check(%+v) => %v", tt.options, err)
+		}
+	}
+}
+
+// bufferCloser records, whether it was closed
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestChunkWriter(t *testing.T) {
+	var tests = []struct {
+		format string
+		size   int
+		items  []string
+		chunks []string
+	}{
+		{"", 0, []string{"a", "b", "c"}, []string{"a\nb\nc\n"}},
+		{"", 2, []string{"a", "b", "c"}, []string{"a\nb\n", "c\n"}},
+		{BulkElasticsearch, 2, []string{"a\nA", "b\nB"}, []string{"a\nA\nb\nB\n"}},
+		{BulkSolr, 2, []string{`"a"`, `"b"`, `"c"`}, []string{"{\n\"a\",\n\"b\"\n}\n", "{\n\"c\"\n}\n"}},
+		{BulkSolr, 0, nil, nil},
+	}
+	for _, tt := range tests {
+		var chunks []*bufferCloser
+		w := NewChunkWriter(tt.format, tt.size, func(chunk int) (io.WriteCloser, error) {
+			if chunk != len(chunks) {
+				t.Errorf("chunk => %d, want: %d", chunk, len(chunks))
+			}
+			b := &bufferCloser{}
+			chunks = append(chunks, b)
+			return b, nil
+		})
+		for _, item := range tt.items {
+			if err := w.Write([]byte(item)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, c := range chunks {
+			if !c.closed {
+				t.Errorf("chunk %d not closed", len(got))
+			}
+			got = append(got, c.String())
+		}
+		if strings.Join(got, "|") != strings.Join(tt.chunks, "|") {
+			t.Errorf("ChunkWriter(%q, %d) => %q, want: %q", tt.format, tt.size, got, tt.chunks)
+		}
+	}
+}
+
+func TestChunkPoster(t *testing.T) {
+	// a stand-in for the bulk API of Elasticsearch, that rejects ids
+	// starting with x and counts the actions
+	var requests, actions int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			http.Error(w, "unexpected content type: "+ct, http.StatusNotAcceptable)
+			return
+		}
+		type item struct {
+			ID     string      `json:"_id"`
+			Status int         `json:"status"`
+			Error  interface{} `json:"error,omitempty"`
+		}
+		var resp struct {
+			Errors bool                         `json:"errors"`
+			Items  []map[string]json.RawMessage `json:"items"`
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]struct {
+				ID string `json:"_id"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for op, meta := range action {
+				actions++
+				result := item{ID: meta.ID, Status: 201}
+				if strings.HasPrefix(meta.ID, "x") {
+					resp.Errors = true
+					result.Status, result.Error = 400, map[string]string{"type": "mapper_parsing_exception"}
+				}
+				b, _ := json.Marshal(result)
+				resp.Items = append(resp.Items, map[string]json.RawMessage{op: b})
+				if op != "delete" && !scanner.Scan() {
+					http.Error(w, "missing document", http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	options := &BulkOptions{Format: BulkElasticsearch, Index: "biblio"}
+	post := func(records ...*marc22.Record) error {
+		w := NewChunkWriter(BulkElasticsearch, 2, ChunkPoster(server.Client(), server.URL+"/_bulk", BulkElasticsearch))
+		for _, record := range records {
+			b, err := options.Marshal(record, []byte(`{"a":1}`))
+			if err != nil {
+				return err
+			}
+			if err := w.Write(b); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	}
+
+	err := post(bulkTestRecord("1", 'c'), bulkTestRecord("2", 'd'), bulkTestRecord("3", 'n'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 || actions != 3 {
+		t.Errorf("requests, actions => %d, %d, want: 2, 3", requests, actions)
+	}
+	err = post(bulkTestRecord("4", 'c'), bulkTestRecord("x5", 'c'))
+	if err == nil || !strings.Contains(err.Error(), "x5") {
+		t.Errorf("post with failed item => %v, want: error for x5", err)
+	}
+
+	// the response status is checked as well
+	solr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var commands map[string]interface{}
+		if err := json.Unmarshal(b, &commands); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := commands["delete"]; ok {
+			http.Error(w, "delete not allowed", http.StatusForbidden)
+		}
+	}))
+	defer solr.Close()
+	options = &BulkOptions{Format: BulkSolr}
+	for _, tt := range []struct {
+		status byte
+		ok     bool
+	}{{'c', true}, {'d', false}} {
+		w := NewChunkWriter(BulkSolr, 0, ChunkPoster(solr.Client(), solr.URL, BulkSolr))
+		b, err := options.Marshal(bulkTestRecord("1", tt.status), []byte(`{"a":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(b); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); (err == nil) != tt.ok {
+			t.Errorf("solr post with status %c => %v", tt.status, err)
+		}
+	}
+}
+
+func TestChunkFanInWriterStops(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	w := NewChunkWriter(BulkElasticsearch, 2, ChunkPoster(server.Client(), server.URL, BulkElasticsearch))
+	in, done, stop := make(chan []byte), make(chan error), make(chan struct{})
+	go ChunkFanInWriter(w, in, done, stop)
+	for i := 0; i < 100; i++ {
+		in <- []byte(`{"index":{"_id":"1"}}` + "\n" + `{"a":1}`)
+	}
+	close(in)
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("ChunkFanInWriter => %v, want: status 500", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests => %d, want: 1", requests)
+	}
+	select {
+	case <-stop:
+	default:
+		t.Errorf("stop not closed after failed request")
+	}
+}
+
+// failingChunk fails on write and records, whether it was closed
+type failingChunk struct {
+	closed int
+}
+
+func (f *failingChunk) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func (f *failingChunk) Close() error {
+	f.closed++
+	return nil
+}
+
+func TestChunkFanInWriterClosesOnError(t *testing.T) {
+	chunk := &failingChunk{}
+	w := NewChunkWriter("", 0, func(int) (io.WriteCloser, error) {
+		return chunk, nil
+	})
+	in, done := make(chan []byte), make(chan error)
+	go ChunkFanInWriter(w, in, done, nil)
+	for i := 0; i < 3; i++ {
+		in <- []byte("a")
+	}
+	close(in)
+	if err := <-done; err == nil || err.Error() != "disk full" {
+		t.Errorf("ChunkFanInWriter => %v, want: disk full", err)
+	}
+	if chunk.closed != 1 {
+		t.Errorf("chunk closed %d times, want: 1", chunk.closed)
+	}
+}
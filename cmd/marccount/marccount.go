@@ -1,47 +1,8 @@
-// Count records in a MARC file
+// Count records in MARC files
 package main
 
-import (
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"runtime/pprof"
-
-	"github.com/ubleipzig/marctools"
-)
+import "github.com/ubleipzig/marctools/internal/cli"
 
 func main() {
-
-	version := flag.Bool("v", false, "prints current program version")
-	cpuprofile := flag.String("cpuprofile", "", "write cpu profile to file")
-
-	var PrintUsage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] MARCFILE\n", os.Args[0])
-		flag.PrintDefaults()
-	}
-
-	flag.Parse()
-
-	if *cpuprofile != "" {
-		f, err := os.Create(*cpuprofile)
-		if err != nil {
-			log.Fatal(err)
-		}
-		pprof.StartCPUProfile(f)
-		defer pprof.StopCPUProfile()
-	}
-
-	if *version {
-		fmt.Println(marctools.AppVersion)
-		os.Exit(0)
-	}
-
-	if flag.NArg() < 1 {
-		PrintUsage()
-		os.Exit(1)
-	}
-
-	count := marctools.RecordCount(flag.Args()[0])
-	fmt.Println(count)
+	cli.Alias("marccount")
 }
@@ -0,0 +1,8 @@
+// Run marctools commands, e.g. marctools tojson file.mrc
+package main
+
+import "github.com/ubleipzig/marctools/internal/cli"
+
+func main() {
+	cli.Main()
+}
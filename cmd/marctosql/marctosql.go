@@ -0,0 +1,8 @@
+// Load MARC records into relational sqlite3 tables for ad-hoc SQL queries
+package main
+
+import "github.com/ubleipzig/marctools/internal/cli"
+
+func main() {
+	cli.Alias("marctosql")
+}
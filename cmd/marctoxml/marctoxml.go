@@ -0,0 +1,8 @@
+// Convert marc to MARCXML.
+package main
+
+import "github.com/ubleipzig/marctools/internal/cli"
+
+func main() {
+	cli.Alias("marctoxml")
+}
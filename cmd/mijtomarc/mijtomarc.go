@@ -0,0 +1,8 @@
+// Convert MARC-in-JSON to binary MARC.
+package main
+
+import "github.com/ubleipzig/marctools/internal/cli"
+
+func main() {
+	cli.Alias("mijtomarc")
+}
@@ -1,13 +1,13 @@
 package marctools
 
 import (
+	"bufio"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -23,85 +23,184 @@ import (
 // AppVersion is displayed by all command line tools
 const AppVersion = "1.6.4"
 
+// JSON output formats, cf. JSONConversionOptions
+const (
+	FormatMarctools = "marctools" // terse, grouped by tag
+	FormatOrdered   = "ordered"   // lossless, fields in record order, cf. OrderedRecordMap
+	FormatMIJ       = "mij"       // MARC-in-JSON, cf. MarshalMIJ
+)
+
 // JsonConversionOptions specify parameters for the MARC to JSON conversion
 type JSONConversionOptions struct {
+	Format        string            // output format, FormatMarctools if empty
 	FilterMap     map[string]bool   // which tags to include
+	Selector      *Selector         // which fields and subfields to include, may be nil
 	MetaMap       map[string]string // meta information
+	MetaSpec      *MetaSpec         // meta values computed per record, may be nil
 	IncludeLeader bool
 	PlainMode     bool // only dump the content
 	IgnoreErrors  bool
 	RecordKey     string
+	Charset       string       // source charset, cf. ToUTF8; empty for no conversion
+	Bulk          *BulkOptions // wrap documents in bulk actions, may be nil
+	// Errors receives conversion errors, if IgnoreErrors is false; workers
+	// keep consuming their input, the receiver decides whether to stop; if
+	// Errors is nil, the workers will exit the program on errors
+	Errors chan<- error
+	// Stop, once closed, lets workers skip the records of remaining batches,
+	// e.g. after the output failed; may be nil
+	Stop <-chan struct{}
+}
+
+// MarshalRecord serializes a single record to JSON according to the given options.
+func MarshalRecord(record *marc22.Record, options JSONConversionOptions) ([]byte, error) {
+	return MarshalRecordOrigin(record, nil, options)
+}
+
+// MarshalRecordOrigin is like MarshalRecord, origin is used for the values of
+// the MetaSpec of the options and may be nil, if the record was not read from
+// a file.
+func MarshalRecordOrigin(record *marc22.Record, origin *RecordOrigin, options JSONConversionOptions) ([]byte, error) {
+	b, err := marshalDocument(record, origin, options)
+	if err != nil || options.Bulk == nil {
+		return b, err
+	}
+	return options.Bulk.Marshal(record, b)
+}
+
+// marshalDocument returns the JSON document for a record
+func marshalDocument(record *marc22.Record, origin *RecordOrigin, options JSONConversionOptions) ([]byte, error) {
+	if err := ToUTF8(record, options.Charset); err != nil {
+		return nil, err
+	}
+	// meta values are taken from the complete record
+	selected := record
+	if options.Selector != nil {
+		selected = options.Selector.Apply(record)
+	}
+	var recordMap map[string]interface{}
+	switch options.Format {
+	case "", FormatMarctools:
+		recordMap = RecordMap(selected, options.FilterMap, options.IncludeLeader)
+	case FormatOrdered:
+		m, err := OrderedRecordMap(selected, options.FilterMap, options.IncludeLeader)
+		if err != nil {
+			return nil, err
+		}
+		recordMap = m
+	case FormatMIJ:
+		return MarshalMIJ(selected, options.FilterMap)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", options.Format)
+	}
+	if options.PlainMode {
+		return json.Marshal(recordMap)
+	}
+	var meta interface{} = options.MetaMap
+	if options.MetaSpec != nil {
+		values, err := options.MetaSpec.Values(record, origin)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range options.MetaMap {
+			if _, ok := values[k]; !ok {
+				values[k] = v
+			}
+		}
+		meta = values
+	}
+	m := map[string]interface{}{
+		options.RecordKey: recordMap,
+		"meta":            meta,
+	}
+	return json.Marshal(m)
+}
+
+// handleError logs an error, if errors should be ignored. Otherwise the
+// error is passed to the errors channel, or, if there is none, the program exits.
+func (options JSONConversionOptions) handleError(err error) {
+	switch {
+	case options.IgnoreErrors:
+		log.Println(err)
+	case options.Errors == nil:
+		log.Fatal(err)
+	default:
+		options.Errors <- err
+	}
+}
+
+// stopped returns true, if Stop is closed
+func (options JSONConversionOptions) stopped() bool {
+	select {
+	case <-options.Stop:
+		return true
+	default:
+		return false
+	}
 }
 
 // Batchworker batches work of MARC records to JSON
+//
+// Deprecated: the order of the output depends on the scheduling of the
+// workers, use OrderedBatchWorker with a Sequencer.
 func BatchWorker(in chan []*marc22.Record, out chan []byte, wg *sync.WaitGroup, options JSONConversionOptions) {
 	defer wg.Done()
 	for records := range in {
 		for _, record := range records {
-			recordMap := RecordMap(record, options.FilterMap, options.IncludeLeader)
-			if options.PlainMode {
-				b, err := json.Marshal(recordMap)
-				if err != nil {
-					if !options.IgnoreErrors {
-						log.Fatal(err)
-					}
-					log.Println(err)
-					continue
-				}
-				out <- b
-			} else {
-				m := map[string]interface{}{
-					options.RecordKey: recordMap,
-					"meta":            options.MetaMap,
-				}
-				b, err := json.Marshal(m)
-				if err != nil {
-					if !options.IgnoreErrors {
-						log.Fatal(err)
-					}
-					log.Println(err)
-					continue
-				}
-				out <- b
+			b, err := MarshalRecord(record, options)
+			if err != nil {
+				options.handleError(err)
+				continue
 			}
+			out <- b
 		}
 	}
 }
 
-// Worker takes a Work item and sends the result (serialized json) on the out channel
-func Worker(in chan *marc22.Record, out chan []byte, wg *sync.WaitGroup, options JSONConversionOptions) {
+// OrderedBatchWorker converts numbered batches of MARC records to JSON, cf.
+// Sequencer, which restores the order of the batches.
+func OrderedBatchWorker(in chan Batch, out chan BatchResult, wg *sync.WaitGroup, options JSONConversionOptions) {
 	defer wg.Done()
-	for record := range in {
-		recordMap := RecordMap(record, options.FilterMap, options.IncludeLeader)
-		if options.PlainMode {
-			b, err := json.Marshal(recordMap)
-			if err != nil {
-				if !options.IgnoreErrors {
-					log.Fatal(err)
-				}
-				log.Println(err)
-				continue
-			}
-			out <- b
-		} else {
-			m := map[string]interface{}{
-				options.RecordKey: recordMap,
-				"meta":            options.MetaMap,
+	for batch := range in {
+		result := BatchResult{Seq: batch.Seq, Items: make([][]byte, 0, len(batch.Records))}
+		if options.stopped() {
+			out <- result
+			continue
+		}
+		for i, record := range batch.Records {
+			var origin *RecordOrigin
+			if i < len(batch.Origins) {
+				origin = batch.Origins[i]
 			}
-			b, err := json.Marshal(m)
+			b, err := MarshalRecordOrigin(record, origin, options)
 			if err != nil {
-				if !options.IgnoreErrors {
-					log.Fatal(err)
-				}
-				log.Println(err)
+				options.handleError(err)
 				continue
 			}
-			out <- b
+			result.Items = append(result.Items, b)
 		}
+		out <- result
+	}
+}
+
+// Worker takes a Work item and sends the result (serialized json) on the out channel
+//
+// Deprecated: use OrderedBatchWorker with a Sequencer.
+func Worker(in chan *marc22.Record, out chan []byte, wg *sync.WaitGroup, options JSONConversionOptions) {
+	defer wg.Done()
+	for record := range in {
+		b, err := MarshalRecord(record, options)
+		if err != nil {
+			options.handleError(err)
+			continue
+		}
+		out <- b
 	}
 }
 
 // FanInWriter writes the channel content to the writer
+//
+// Deprecated: write errors are lost, use ChunkFanInWriter.
 func FanInWriter(writer io.Writer, in chan []byte, done chan bool) {
 	for b := range in {
 		writer.Write(b)
@@ -146,23 +245,50 @@ func StringToMapSet(s string) map[string]bool {
 // RecordLength returns the length of the marc record as stored in the leader
 func RecordLength(reader io.Reader) (length int64, err error) {
 	data := make([]byte, 24)
-	n, err := reader.Read(data)
-	if err != nil {
-		return 0, err
-	}
-	if n != 24 {
-		return 0, fmt.Errorf("marc: invalid leader: expected 24 bytes, read %d", n)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if err == io.EOF {
+			return 0, err
+		}
+		return 0, leaderLengthError(err)
 	}
-	l, err := strconv.Atoi(string(data[0:5]))
+	return parseRecordLength(data)
+}
+
+// leaderLengthError is returned, if less than 24 bytes could be read.
+func leaderLengthError(err error) error {
+	return fmt.Errorf("marc: %w: expected 24 bytes: %s", ErrInvalidLeader, err)
+}
+
+// parseRecordLength returns the record length found in the first five bytes
+// of a leader.
+func parseRecordLength(leader []byte) (int64, error) {
+	l, err := strconv.Atoi(string(leader[0:5]))
 	if err != nil {
-		return 0, fmt.Errorf("marc: invalid record length: %s", err)
+		return 0, fmt.Errorf("marc: %w: invalid record length: %s", ErrInvalidLeader, err)
+	}
+	if l < 24 {
+		return 0, fmt.Errorf("marc: %w: record length too short: %d", ErrInvalidLeader, l)
 	}
 	return int64(l), nil
 }
 
+// CountRecords returns the number of records found in a reader.
+func CountRecords(reader io.Reader) (int64, error) {
+	r := NewReader(reader)
+	for {
+		_, err := r.Next()
+		if err == io.EOF {
+			return r.index, nil
+		}
+		if err != nil {
+			return r.index, err
+		}
+	}
+}
+
 // RecordCount count the number of records in marc file
 func RecordCount(filename string) int64 {
-	handle, err := os.Open(filename)
+	handle, err := OpenInput(filename)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -173,242 +299,321 @@ func RecordCount(filename string) int64 {
 		}
 	}()
 
-	var i, offset int64
+	count, err := CountRecords(handle)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return count
+}
 
-	for {
-		length, err := RecordLength(handle)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		i++
-		offset += length
-		handle.Seek(offset, 0)
+// Identifiers returns a slice of strings, containing all ids of the given
+// marc file. Set safe to true to use the slower, more safe method of parsing
+// each record. The fast method only looks at the directory and identifier
+// field of each record. Both methods use the first 001 field, if there are
+// multiple (invalid, but real-world).
+func Identifiers(filename string, safe bool) ([]string, error) {
+	return DefaultIdentifierSpec.Identifiers(filename, safe)
+}
+
+// Identifiers returns all ids of the given marc file, cf. Identifiers.
+func (spec *IdentifierSpec) Identifiers(filename string, safe bool) ([]string, error) {
+	var ids []string
+	err := spec.walkIdentifiers(filename, safe, func(e MapEntry) error {
+		ids = append(ids, e.ID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return i
+	return ids, nil
 }
 
 // IdentifierList returns a slice of strings, containing all ids of the given
-// marc file. Set safe to true to use the slower, more safe method of parsing
-// each record. Fast method breaks when there are multiple 001 fields (invalid,
-// but real-world).
+// marc file. Like Identifiers, but exits the program on errors.
 func IdentifierList(filename string, safe bool) []string {
-	var fallback bool
-	var yaz, awk string
-	var err error
+	ids, err := Identifiers(filename, safe)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return ids
+}
 
-	if !safe {
-		yaz, err = exec.LookPath("yaz-marcdump")
-		if err != nil {
-			fallback = true
+// MapEntry contains location information of a single record in a MARC file
+type MapEntry struct {
+	ID     string
+	Offset int64
+	Length int64
+}
+
+// identifierFunc returns a function, that extracts the identifier from a raw
+// record. In fast mode, the record is not parsed, only the directory is used
+// to locate the identifier fields.
+func (spec *IdentifierSpec) identifierFunc(filename string, safe bool) func(*RawRecord) (string, error) {
+	return func(rr *RawRecord) (string, error) {
+		var id string
+		var err error
+		if safe {
+			var record *marc22.Record
+			if record, err = rr.Record(); err != nil {
+				return "", err
+			}
+			id, err = spec.Identifier(record)
+		} else {
+			id, err = spec.rawIdentifier(rr.Data)
 		}
-		awk, err = exec.LookPath("awk")
 		if err != nil {
-			fallback = true
+			// Cf. https://github.com/ubleipzig/marctools/issues/5
+			return "", &RecordError{Filename: filename, Index: rr.Index, Offset: rr.Offset, Err: err}
 		}
+		return id, nil
 	}
+}
 
-	var ids []string
+// WalkRecords calls fn with the identifier and the raw record for each
+// record in the given file. Iteration stops at the first error, which is
+// returned. Errors returned by fn are passed through unchanged. Compressed
+// files are supported, offsets refer to the uncompressed data.
+func WalkRecords(infile string, safe bool, fn func(id string, rr *RawRecord) error) error {
+	return DefaultIdentifierSpec.WalkRecords(infile, safe, fn)
+}
 
-	if fallback || safe {
-		// use slower iteration over records
-		fi, err := os.Open(filename)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer func() {
-			if err := fi.Close(); err != nil {
-				log.Fatal(err)
-			}
-		}()
+// WalkRecords calls fn with the identifier and the raw record for each
+// record in the given file, cf. WalkRecords.
+func (spec *IdentifierSpec) WalkRecords(infile string, safe bool, fn func(id string, rr *RawRecord) error) error {
+	identifier := spec.identifierFunc(infile, safe)
 
-		for {
-			record, err := marc22.ReadRecord(fi)
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				log.Fatal(err)
-			}
+	handle, err := OpenInput(infile)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
 
-			fields := record.GetControlFields("001")
-			// Cf. https://github.com/ubleipzig/marctools/issues/5
-			// In case of multiple identifiers, choose the first.
-			if len(fields) == 0 {
-				log.Fatalf("missing 001 field")
-			}
-			ids = append(ids, strings.TrimSpace(fields[0].Data))
+	reader := NewReader(handle)
+	reader.Filename = infile
+	for {
+		rr, err := reader.Next()
+		if err == io.EOF {
+			break
 		}
-	} else {
-		// fast version using yaz and awk
-		command := fmt.Sprintf("%s '%s' | %s ' /^001 / {print $2}'", yaz, filename, awk)
-		out, err := exec.Command("bash", "-c", command).Output()
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-
-		for _, line := range strings.Split(string(out), "\n") {
-			line = strings.TrimSpace(line)
-			if len(line) == 0 {
-				continue
-			}
-			ids = append(ids, strings.TrimSpace(line))
+		id, err := identifier(rr)
+		if err != nil {
+			return err
+		}
+		if err := fn(id, rr); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return ids
+// WalkMapEntries calls fn for each record in the given file. Iteration stops
+// at the first error, which is returned. Errors returned by fn are passed
+// through unchanged.
+func WalkMapEntries(infile string, safe bool, fn func(MapEntry) error) error {
+	return DefaultIdentifierSpec.walkIdentifiers(infile, safe, fn)
 }
 
-// MapEntry contains location information of a single record in a MARC file
-type MapEntry struct {
-	ID     string
-	Offset int64
-	Length int64
+// WalkMapEntries calls fn for each record in the given file, cf.
+// WalkMapEntries.
+func (spec *IdentifierSpec) WalkMapEntries(infile string, safe bool, fn func(MapEntry) error) error {
+	return spec.walkIdentifiers(infile, safe, fn)
+}
+
+// walkIdentifiers calls fn for each record in the given file. In fast mode,
+// records are not copied, cf. scanRecords.
+func (spec *IdentifierSpec) walkIdentifiers(infile string, safe bool, fn func(MapEntry) error) error {
+	if safe {
+		return spec.WalkRecords(infile, safe, func(id string, rr *RawRecord) error {
+			return fn(MapEntry{ID: id, Offset: rr.Offset, Length: rr.Length})
+		})
+	}
+
+	handle, err := OpenInput(infile)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	identifier := spec.identifierFunc(infile, safe)
+	return scanRecords(handle, infile, func(rr *RawRecord) error {
+		id, err := identifier(rr)
+		if err != nil {
+			return err
+		}
+		return fn(MapEntry{ID: id, Offset: rr.Offset, Length: rr.Length})
+	})
 }
 
-// MarcMapEntries returns a chan of MapEntry structs.
+// MarcMapEntries returns a chan of MapEntry structs. Exits the program on
+// errors, use WalkMapEntries to handle errors.
 func MarcMapEntries(infile string, safe bool) chan MapEntry {
 	c := make(chan MapEntry)
 	go func() {
-		handle, err := os.Open(infile)
+		err := WalkMapEntries(infile, safe, func(e MapEntry) error {
+			c <- e
+			return nil
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
-		defer func() {
-			if err := handle.Close(); err != nil {
-				log.Fatal(err)
-			}
-		}()
-
-		ids := IdentifierList(infile, safe)
-		var i, offset int64
-
-		for {
-			length, err := RecordLength(handle)
-			if err == io.EOF {
-				break
-			}
-			c <- MapEntry{ID: ids[i], Offset: offset, Length: length}
-			offset += length
-			i++
-			handle.Seek(offset, 0)
-		}
 		close(c)
 	}()
 	return c
 }
 
-// MarcMap writes (id, offset, length) TSV of a given MARC file to a io.Writer
+// WriteMarcMap writes (id, offset, length) TSV of a given MARC file to a
+// io.Writer. For compressed files, offsets refer to the uncompressed data.
+func WriteMarcMap(infile string, writer io.Writer, safe bool) error {
+	return DefaultIdentifierSpec.WriteMarcMap(infile, writer, safe)
+}
+
+// WriteMarcMap writes (id, offset, length) TSV of a given MARC file to a
+// io.Writer, using the identifiers given by the spec.
+func (spec *IdentifierSpec) WriteMarcMap(infile string, writer io.Writer, safe bool) error {
+	return spec.walkIdentifiers(infile, safe, func(e MapEntry) error {
+		_, err := fmt.Fprintf(writer, "%s\t%d\t%d\n", e.ID, e.Offset, e.Length)
+		return err
+	})
+}
+
+// MarcMap writes (id, offset, length) TSV of a given MARC file to a
+// io.Writer. Exits the program on errors.
 func MarcMap(infile string, writer io.Writer, safe bool) {
-	for e := range MarcMapEntries(infile, safe) {
-		writer.Write([]byte(fmt.Sprintf("%s\t%d\t%d\n", e.ID, e.Offset, e.Length)))
+	if err := WriteMarcMap(infile, writer, safe); err != nil {
+		log.Fatal(err)
 	}
 }
 
-// MarcMapSqlite writes (id, offset, length) sqlite3 database of a given MARC file to given output file
-func MarcMapSqlite(infile, outfile string, safe bool) {
+// WriteMarcMapSqlite writes (id, offset, length) sqlite3 database of a given
+// MARC file to given output file
+func WriteMarcMapSqlite(infile, outfile string, safe bool) error {
+	return DefaultIdentifierSpec.WriteMarcMapSqlite(infile, outfile, safe)
+}
+
+// WriteMarcMapSqlite writes (id, offset, length) sqlite3 database of a given
+// MARC file, using the identifiers given by the spec.
+func (spec *IdentifierSpec) WriteMarcMapSqlite(infile, outfile string, safe bool) error {
 	db, err := sql.Open("sqlite3", outfile)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer db.Close()
 
 	init := `CREATE TABLE IF NOT EXISTS seekmap (id text, offset int, length int)`
-	_, err = db.Exec(init)
-	if err != nil {
-		log.Fatalf("%q: %s\n", err, init)
+	if _, err = db.Exec(init); err != nil {
+		return fmt.Errorf("%w: %s", err, init)
 	}
 
 	tx, err := db.Begin()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	stmt, err := tx.Prepare("INSERT INTO seekmap VALUES (?, ?, ?)")
 	if err != nil {
-		log.Fatal(err)
+		tx.Rollback()
+		return err
 	}
 	defer stmt.Close()
 
-	for e := range MarcMapEntries(infile, safe) {
-		_, err = stmt.Exec(e.ID, e.Offset, e.Length)
-		if err != nil {
-			log.Fatal(err)
-		}
+	err = spec.walkIdentifiers(infile, safe, func(e MapEntry) error {
+		_, err := stmt.Exec(e.ID, e.Offset, e.Length)
+		return err
+	})
+	if err != nil {
+		tx.Rollback()
+		return err
 	}
 
-	_, err = tx.Exec("CREATE INDEX idx_seekmap_id ON seekmap (id)")
-	if err != nil {
-		log.Fatal(err)
+	if _, err = tx.Exec("CREATE INDEX idx_seekmap_id ON seekmap (id)"); err != nil {
+		tx.Rollback()
+		return err
 	}
-	tx.Commit()
+	return tx.Commit()
 }
 
-// writeSplit writes bytes beginning at offset from file into output
-// number of bytes copied is given by the buffer length
-func writeSplit(file, output *os.File, offset int64, buffer []byte) {
-	file.Seek(offset, 0)
-	file.Read(buffer)
-	output.Write(buffer)
-	err := output.Close()
-	if err != nil {
+// MarcMapSqlite writes (id, offset, length) sqlite3 database of a given MARC
+// file to given output file. Exits the program on errors.
+func MarcMapSqlite(infile, outfile string, safe bool) {
+	if err := WriteMarcMapSqlite(infile, outfile, safe); err != nil {
 		log.Fatal(err)
 	}
 }
 
 // createSplitFile returns a writeable file object
-func createSplitFile(directory, prefix string, fileno int64) *os.File {
+func createSplitFile(directory, prefix string, fileno int64) (*os.File, error) {
 	filename := filepath.Join(directory, fmt.Sprintf("%s%08d", prefix, fileno))
-	output, err := os.Create(filename)
-	if err != nil {
-		log.Fatal(err)
-	}
-	return output
+	return os.Create(filename)
 }
 
-// MarcSplitDirectoryPrefix splits a file into parts, each containing at most size records
+// SplitFile splits a file into parts, each containing at most size records
 // and writes the to specified directory, using a specific prefix
-func MarcSplitDirectoryPrefix(infile string, size int64, directory, prefix string) {
-	file, err := os.Open(infile)
-	if err != nil {
-		log.Fatal(err)
-	}
+func SplitFile(infile string, size int64, directory, prefix string) error {
+	return SplitFiles([]string{infile}, size, directory, prefix)
+}
 
-	defer func() {
-		if err := file.Close(); err != nil {
-			log.Fatal(err)
-		}
-	}()
+// SplitFiles splits a number of files, read as a single stream, into parts,
+// cf. SplitFile.
+func SplitFiles(infiles []string, size int64, directory, prefix string) error {
+	if size < 1 {
+		return fmt.Errorf("invalid split size: %d", size)
+	}
+	reader := NewMultiReader(infiles)
+	defer reader.Close()
 
-	var i, length, cumulative, offset, batch, fileno int64
+	var fileno, count int64
+	output, err := createSplitFile(directory, prefix, fileno)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(output)
 
 	for {
-		length, err = RecordLength(file)
+		rr, err := reader.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			log.Fatal(err)
+			output.Close()
+			return err
 		}
-		if i%size == 0 && i > 0 {
-			output := createSplitFile(directory, prefix, fileno)
-			buffer := make([]byte, batch)
-			writeSplit(file, output, offset, buffer)
-
-			batch = 0
+		if count%size == 0 && count > 0 {
+			if err := closeSplitFile(output, writer); err != nil {
+				return err
+			}
 			fileno++
-			offset = cumulative
+			if output, err = createSplitFile(directory, prefix, fileno); err != nil {
+				return err
+			}
+			writer.Reset(output)
+		}
+		if _, err := writer.Write(rr.Data); err != nil {
+			output.Close()
+			return err
 		}
-		cumulative += length
-		batch += length
-		file.Seek(int64(cumulative), 0)
-		i++
+		count++
 	}
+	return closeSplitFile(output, writer)
+}
 
-	output := createSplitFile(directory, prefix, fileno)
-	buffer := make([]byte, batch)
-	writeSplit(file, output, offset, buffer)
+// closeSplitFile flushes the buffer and closes the file
+func closeSplitFile(output *os.File, writer *bufio.Writer) error {
+	if err := writer.Flush(); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
+
+// MarcSplitDirectoryPrefix splits a file into parts, each containing at most size records
+// and writes the to specified directory, using a specific prefix. Exits the
+// program on errors.
+func MarcSplitDirectoryPrefix(infile string, size int64, directory, prefix string) {
+	if err := SplitFile(infile, size, directory, prefix); err != nil {
+		log.Fatal(err)
+	}
 }
 
 // MarcSplitDirectory splits a file into parts, each containing at most size records
@@ -472,47 +677,121 @@ func recordMap(record *marc22.Record, filter map[string]bool) map[string]interfa
 
 // RecordMap converts a record to a map, optionally keeping only the tags
 // given in filter. If includeLeader is true, the leader is converted as well.
+// Fields are grouped by tag, so the order of fields with different tags is
+// lost, as are repeated control fields, cf. OrderedRecordMap.
 func RecordMap(record *marc22.Record, filter map[string]bool, includeLeader bool) map[string]interface{} {
-	rmap := recordMap(record, filter)
+	rmap := recordMap(LegacySubFields(record), filter)
 	if includeLeader {
+		// the raw leader as read, leader.Bytes() would reset positions 20-23
 		leader := record.LeaderParsed
-		l := map[string]string{
-			"status":  string(leader.Status),
-			"cs":      string(leader.CharacterEncoding),
-			"length":  fmt.Sprintf("%d", leader.Length),
-			"type":    string(leader.Type),
-			"impldef": string(leader.ImplementationDefined[:5]),
-			"ic":      fmt.Sprintf("%d", leader.IndicatorCount),
-			"lol":     fmt.Sprintf("%d", leader.LengthOfLength),
-			"losp":    fmt.Sprintf("%d", leader.LengthOfStartPos),
-			"sfcl":    fmt.Sprintf("%d", leader.SubfieldCodeLength),
-			"ba":      fmt.Sprintf("%d", leader.BaseAddress),
-			"raw":     string(leader.Bytes()),
-		}
-		rmap["leader"] = l
+		raw, err := leaderBytes(record)
+		if err != nil {
+			raw = leader.Bytes()
+		}
+		rmap["leader"] = leaderMap(leader, string(raw))
 	}
 	return rmap
 }
 
+// OrderedRecordMap converts a record to a map with the fields in record
+// order, in MARC-in-JSON notation, under the key "fields". Unlike RecordMap,
+// every field is kept, including repeated control fields, as well as the
+// order of all fields and subfields, and the raw leader is taken from the
+// record as is. Filter and includeLeader work as for RecordMap.
+func OrderedRecordMap(record *marc22.Record, filter map[string]bool, includeLeader bool) (map[string]interface{}, error) {
+	fields, err := mijFields(record, filter)
+	if err != nil {
+		return nil, err
+	}
+	rmap := map[string]interface{}{"fields": fields}
+	if includeLeader {
+		raw, err := leaderBytes(record)
+		if err != nil {
+			return nil, err
+		}
+		rmap["leader"] = leaderMap(record.LeaderParsed, string(raw))
+	}
+	return rmap, nil
+}
+
+// leaderMap converts a parsed leader to a map, with the given raw value
+func leaderMap(leader *marc22.Leader, raw string) map[string]string {
+	return map[string]string{
+		"status":  string(leader.Status),
+		"cs":      string(leader.CharacterEncoding),
+		"length":  fmt.Sprintf("%d", leader.Length),
+		"type":    string(leader.Type),
+		"impldef": string(leader.ImplementationDefined[:5]),
+		"ic":      fmt.Sprintf("%d", leader.IndicatorCount),
+		"lol":     fmt.Sprintf("%d", leader.LengthOfLength),
+		"losp":    fmt.Sprintf("%d", leader.LengthOfStartPos),
+		"sfcl":    fmt.Sprintf("%d", leader.SubfieldCodeLength),
+		"ba":      fmt.Sprintf("%d", leader.BaseAddress),
+		"raw":     raw,
+	}
+}
+
 var regexSubfield = regexp.MustCompile(`^([\d]{3})\.([a-z0-9])$`)
 var regexControlfield = regexp.MustCompile(`^[\d]{3}$`)
 
-// RecordToSlice returns a string slice with the values of the given tags
-func RecordToSlice(record *marc22.Record,
+// regexFieldSelector matches columns, that are a single selector term with
+// wildcards, indicators or multiple subfield codes, cf. Selector
+var regexFieldSelector = regexp.MustCompile(`^[\dX?]{3}(\[..\])?(\.[a-z0-9]+)?$`)
+
+// ColumnSelectors parses the tags of RecordValues, that are selectors, e.g.
+// 6XX.a or 856[4?].u; the selectors of other tags are nil.
+func ColumnSelectors(tags []string) ([]*Selector, error) {
+	selectors := make([]*Selector, len(tags))
+	for i, tag := range tags {
+		if regexControlfield.MatchString(tag) || regexSubfield.MatchString(tag) || !regexFieldSelector.MatchString(tag) {
+			continue
+		}
+		selector, err := ParseSelector(tag)
+		if err != nil {
+			return nil, err
+		}
+		selectors[i] = selector
+	}
+	return selectors, nil
+}
+
+// RecordValues returns a string slice with the values of the given tags.
+// Selectors are parsed on each call, use ColumnSelectors and RecordColumns
+// for many records.
+func RecordValues(record *marc22.Record,
 	tags []string,
 	fillna, separator string,
-	skipIncompleteLines bool) []string {
+	skipIncompleteLines bool) ([]string, error) {
+
+	selectors, err := ColumnSelectors(tags)
+	if err != nil {
+		return nil, err
+	}
+	return RecordColumns(record, tags, selectors, fillna, separator, skipIncompleteLines)
+}
+
+// RecordColumns works like RecordValues, with the selectors of the tags
+// parsed by ColumnSelectors.
+func RecordColumns(record *marc22.Record,
+	tags []string,
+	selectors []*Selector,
+	fillna, separator string,
+	skipIncompleteLines bool) ([]string, error) {
+
+	if len(selectors) != len(tags) {
+		return nil, fmt.Errorf("got %d selectors for %d tags", len(selectors), len(tags))
+	}
 
 	var cols []string
 
-	for _, tag := range tags {
+	for i, tag := range tags {
 		if regexControlfield.MatchString(tag) {
 			fields := record.GetControlFields(tag)
 			if len(fields) > 0 {
 				cols = append(cols, fields[0].Data)
 			} else {
 				if skipIncompleteLines {
-					return []string{}
+					return []string{}, nil
 				}
 				cols = append(cols, fillna)
 			}
@@ -532,7 +811,21 @@ func RecordToSlice(record *marc22.Record,
 				}
 			} else {
 				if skipIncompleteLines {
-					return []string{}
+					return []string{}, nil
+				}
+				cols = append(cols, fillna)
+			}
+		} else if selectors[i] != nil {
+			values := selectors[i].Values(record)
+			if len(values) > 0 {
+				if separator == "" {
+					cols = append(cols, values[0])
+				} else {
+					cols = append(cols, strings.Join(values, separator))
+				}
+			} else {
+				if skipIncompleteLines {
+					return []string{}, nil
 				}
 				cols = append(cols, fillna)
 			}
@@ -560,12 +853,26 @@ func RecordToSlice(record *marc22.Record,
 			case "@LengthOfStartPos":
 				cols = append(cols, fmt.Sprintf("%d", leader.LengthOfStartPos))
 			default:
-				log.Fatalf("unknown tag: %s\n", tag)
+				return nil, fmt.Errorf("%w: %s", ErrUnknownTag, tag)
 			}
 		} else if !strings.HasPrefix(tag, "-") {
 			cols = append(cols, strings.TrimSpace(tag))
 		}
 	}
+	return cols, nil
+}
+
+// RecordToSlice returns a string slice with the values of the given tags.
+// Exits the program on unknown tags.
+func RecordToSlice(record *marc22.Record,
+	tags []string,
+	fillna, separator string,
+	skipIncompleteLines bool) []string {
+
+	cols, err := RecordValues(record, tags, fillna, separator, skipIncompleteLines)
+	if err != nil {
+		log.Fatal(err)
+	}
 	return cols
 }
 
@@ -582,3 +889,25 @@ func RecordToTSV(record *marc22.Record,
 	}
 	return result
 }
+
+// ColumnNames returns a name for each column RecordValues returns for the
+// given tags, which is the tag itself, e.g. 245.a or @Status.
+func ColumnNames(tags []string) []string {
+	var names []string
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, "-") {
+			names = append(names, strings.TrimSpace(tag))
+		}
+	}
+	return names
+}
+
+// tsvEscaper escapes values for TSV, cf. EscapeTSV
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// EscapeTSV escapes backslashes, tabs and line breaks in a value as \\, \t,
+// \n and \r, the convention of PostgreSQL COPY and MySQL LOAD DATA, so each
+// value stays in its column and each record on its line.
+func EscapeTSV(s string) string {
+	return tsvEscaper.Replace(s)
+}
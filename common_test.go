@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -37,6 +39,46 @@ func TestCount(t *testing.T) {
 	}
 }
 
+func TestCountRecords(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out int64
+		err error
+	}{
+		{"", 0, nil},
+		{"00092     2200061   4500", 0, io.ErrUnexpectedEOF},
+		{"00092", 0, ErrInvalidLeader},
+		{"000xx     2200061   4500", 0, ErrInvalidLeader},
+		{"00010     2200061   4500", 0, ErrInvalidLeader},
+	}
+
+	for _, tt := range tests {
+		count, err := CountRecords(strings.NewReader(tt.in))
+		if count != tt.out {
+			t.Errorf("CountRecords(%q) => %d, want: %d", tt.in, count, tt.out)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("CountRecords(%q) => err %v, want: %v", tt.in, err, tt.err)
+		}
+		if err != nil {
+			var rerr *RecordError
+			if !errors.As(err, &rerr) {
+				t.Errorf("CountRecords(%q) => err %T, want: *RecordError", tt.in, err)
+			}
+		}
+	}
+
+	file, err := os.Open("./fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	count, err := CountRecords(file)
+	if err != nil || count != 10 {
+		t.Errorf("CountRecords(journals.mrc) => %d, %v, want: 10, nil", count, err)
+	}
+}
+
 func TestIDList(t *testing.T) {
 	var tests = []struct {
 		in  string
@@ -45,14 +87,15 @@ func TestIDList(t *testing.T) {
 		{"./fixtures/deweybrowse.mrc", []string{"testdeweybrowse"}},
 		{"./fixtures/testbug2.mrc", []string{"testbug2"}},
 		{"./fixtures/heb.mrc", []string{"testbug1"}},
-		// {"./fixtures/weird_ids.mrc", []string{`|||pipe|||"quote`,
-		//  `dot.dash-underscore__3.colon:18`,
-		//  `dot.dash-underscore__3.space`,
-		//  `dollar$ign/slashcombo`,
-		//  `all'kinds"of'quotes"`,
-		//  `<angle>brackets&ampersands`,
-		//  `hashes#coming@ya`,
-		//  `wehave?sand%stospare`}},
+		{"./fixtures/weird_ids.mrc", []string{`|||pipe|||"quote`,
+			`dot.dash-underscore__3.colon:18`,
+			`dot.dash-underscore__3.space suffix`,
+			`dollar$ign/slashcombo`,
+			`all'kinds"of'quotes"`,
+			`<angle>brackets&ampersands`,
+			`hashes#coming@ya`,
+			`wehave?sand%stospare`}},
+		{"./fixtures/issue-5.mrc", []string{"u1033898", "u1033899", "u1033900"}},
 		{"./fixtures/journals.mrc", []string{"testsample1",
 			"testsample2",
 			"testsample3",
@@ -66,14 +109,16 @@ func TestIDList(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		ids := IdentifierList(tt.in, false)
-		if len(ids) != len(tt.out) {
-			t.Errorf("IDList(%s) => %+v, want: %+v", tt.in, ids, tt.out)
-		}
-		for i := 0; i < len(ids); i++ {
-			if ids[i] != tt.out[i] {
-				t.Errorf("List element mismatch in IDList(%s)[%d] => %+v, want: %+v",
-					tt.in, i, ids[i], tt.out[i])
+		for _, safe := range []bool{false, true} {
+			ids := IdentifierList(tt.in, safe)
+			if len(ids) != len(tt.out) {
+				t.Errorf("IDList(%s, %v) => %+v, want: %+v", tt.in, safe, ids, tt.out)
+			}
+			for i := 0; i < len(ids); i++ {
+				if ids[i] != tt.out[i] {
+					t.Errorf("List element mismatch in IDList(%s, %v)[%d] => %+v, want: %+v",
+						tt.in, safe, i, ids[i], tt.out[i])
+				}
 			}
 		}
 	}
@@ -109,6 +154,27 @@ func TestMarcMap(t *testing.T) {
 	}
 }
 
+func TestWriteMarcMapErrors(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteMarcMap("./fixtures/does-not-exist.mrc", &b, true)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("WriteMarcMap(does-not-exist.mrc) => %v, want: not exist error", err)
+	}
+
+	stop := errors.New("stop")
+	var n int
+	err = WalkMapEntries("./fixtures/journals.mrc", true, func(e MapEntry) error {
+		n++
+		if n == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 2 {
+		t.Errorf("WalkMapEntries => %v after %d entries, want: %v after 2", err, n, stop)
+	}
+}
+
 func TestMarcMapSqlite(t *testing.T) {
 	file, err := ioutil.TempFile("", "marctools-TestMarcMapSqlite-")
 	if err != nil {
@@ -298,6 +364,13 @@ var recordToTSVTests = []struct {
 		true,
 		"testdeweybrowse\t8820737493\t123.45 .I39|123.46 .Q39\n",
 	},
+	{`00613cam a2200229Ma 4500001001600000005001700016008004100033020001500074035002300089040002500112041001800137043001200155050002400167049000900191082001600200082001600216100003000232245002200262250002300284260004700307300002900354testdeweybrowse20110419140028.0110214s1992    it a     b    001 0 ita d  a8820737493  a(OCoLC)ocm30585539  aRBNcRBNdOCLCGdPVU1 aitaalathlat  ae-it---14aDG848.15b.V53 1992  aPVUM  a123.45 .I39  a123.46 .Q391 aPerson, Fake,d1668-1744.10aDewey browse test  aFictional edition.  aMorano :bCentro di Studi Vichiani,c1992.  a296 p. :bill. ;c24 cm.`,
+		[]string{"001", "08X.a", "100[1#].ad", "6XX"},
+		"<NULL>",
+		"|",
+		false,
+		"testdeweybrowse\t123.45 .I39|123.46 .Q39\tPerson, Fake,|1668-1744.\t<NULL>\n",
+	},
 	{`00613cam a2200229Ma 4500001001600000005001700016008004100033020001500074035002300089040002500112041001800137043001200155050002400167049000900191082001600200082001600216100003000232245002200262250002300284260004700307300002900354testdeweybrowse20110419140028.0110214s1992    it a     b    001 0 ita d  a8820737493  a(OCoLC)ocm30585539  aRBNcRBNdOCLCGdPVU1 aitaalathlat  ae-it---14aDG848.15b.V53 1992  aPVUM  a123.45 .I39  a123.46 .Q391 aPerson, Fake,d1668-1744.10aDewey browse test  aFictional edition.  aMorano :bCentro di Studi Vichiani,c1992.  a296 p. :bill. ;c24 cm.`,
 		[]string{"999"},
 		"<NULL>",
@@ -321,6 +394,39 @@ var recordToTSVTests = []struct {
 	},
 }
 
+func TestRecordValuesUnknownTag(t *testing.T) {
+	record, err := marc22.ReadRecord(strings.NewReader(recordToTSVTests[0].record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = RecordValues(record, []string{"001", "@Unknown"}, "<NULL>", "", false)
+	if !errors.Is(err, ErrUnknownTag) {
+		t.Errorf("RecordValues(@Unknown) => %v, want: %v", err, ErrUnknownTag)
+	}
+}
+
+func TestColumnSelectors(t *testing.T) {
+	tags := []string{"001", "245.a", "6XX.a", "856[4?].u", "@Status", "x"}
+	selectors, err := ColumnSelectors(tags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, selector := range selectors {
+		if selector == nil {
+			got = append(got, "")
+			continue
+		}
+		got = append(got, selector.String())
+	}
+	if want := []string{"", "", "6XX.a", "856[4?].u", "", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ColumnSelectors(%v) => %q, want: %q", tags, got, want)
+	}
+	if _, err := RecordColumns(selectorTestRecord, tags, selectors[:1], "", "", false); err == nil {
+		t.Errorf("RecordColumns with %d selectors for %d tags => nil, want: error", 1, len(tags))
+	}
+}
+
 func TestRecordToTSV(t *testing.T) {
 	for _, tt := range recordToTSVTests {
 		reader := strings.NewReader(tt.record)
@@ -334,3 +440,90 @@ func TestRecordToTSV(t *testing.T) {
 		}
 	}
 }
+
+var columnNamesTests = []struct {
+	tags []string
+	out  []string
+}{
+	{[]string{"001"}, []string{"001"}},
+	{[]string{"001", "245.a", "@Status"}, []string{"001", "245.a", "@Status"}},
+	{[]string{"001", "-x", "ebook"}, []string{"001", "ebook"}},
+	{[]string{}, nil},
+}
+
+func TestColumnNames(t *testing.T) {
+	for _, tt := range columnNamesTests {
+		out := ColumnNames(tt.tags)
+		if !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("ColumnNames(%v) => %v, want: %v", tt.tags, out, tt.out)
+		}
+	}
+}
+
+var escapeTSVTests = []struct {
+	in  string
+	out string
+}{
+	{"", ""},
+	{"Rational living.", "Rational living."},
+	{"a\tb", `a\tb`},
+	{"a\r\nb", `a\r\nb`},
+	{`C:\path`, `C:\\path`},
+	{"\\t", `\\t`},
+}
+
+func TestEscapeTSV(t *testing.T) {
+	for _, tt := range escapeTSVTests {
+		out := EscapeTSV(tt.in)
+		if out != tt.out {
+			t.Errorf("EscapeTSV(%q) => %q, want: %q", tt.in, out, tt.out)
+		}
+	}
+}
+
+func TestOrderedRecordMap(t *testing.T) {
+	record, err := marc22.ReadRecord(strings.NewReader(recordMapTests[3].record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// repeated control fields and subfields keep their order
+	repeated := &marc22.Record{
+		Leader: "00000nam a2200000 a 4500",
+		ControlFields: []marc22.ControlField{
+			{Tag: "001", Data: "1"},
+			{Tag: "001", Data: "2"},
+		},
+		DataFields: []marc22.DataField{
+			{Tag: "245", Ind1: "1", Ind2: "0", SubFields: []*marc22.SubField{
+				{Code: "a", Value: "Title"},
+				{Code: "b", Value: "Subtitle"},
+				{Code: "a", Value: "More"},
+			}},
+		},
+	}
+	var tests = []struct {
+		record    *marc22.Record
+		filterMap map[string]bool
+		out       string
+	}{
+		{record, nil,
+			`{"fields":[{"001":"12345"},{"040":{"ind1":" ","ind2":" ","subfields":[{"a":"Value 1"}]}},{"040":{"ind1":" ","ind2":" ","subfields":[{"a":"Value 2"}]}}]}`},
+		{repeated, nil,
+			`{"fields":[{"001":"1"},{"001":"2"},{"245":{"ind1":"1","ind2":"0","subfields":[{"a":"Title"},{"b":"Subtitle"},{"a":"More"}]}}]}`},
+		{repeated, map[string]bool{"245": true},
+			`{"fields":[{"245":{"ind1":"1","ind2":"0","subfields":[{"a":"Title"},{"b":"Subtitle"},{"a":"More"}]}}]}`},
+	}
+	for _, tt := range tests {
+		result, err := OrderedRecordMap(tt.record, tt.filterMap, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.out {
+			t.Errorf("OrderedRecordMap(%v, %v) => %s, want: %s", tt.record, tt.filterMap, b, tt.out)
+		}
+	}
+}
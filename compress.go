@@ -0,0 +1,97 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Supported compression formats, as returned by DetectCompression
+const (
+	CompressionNone  = ""
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+)
+
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// compression returns the compression format indicated by the magic bytes
+func compression(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return CompressionBzip2
+	default:
+		return CompressionNone
+	}
+}
+
+// Decompress returns a reader, that transparently decompresses gzip or bzip2
+// compressed data. The format is detected by magic bytes, uncompressed data
+// is passed through unchanged.
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch compression(magic) {
+	case CompressionGzip:
+		return gzip.NewReader(br)
+	case CompressionBzip2:
+		return bzip2.NewReader(br), nil
+	default:
+		return br, nil
+	}
+}
+
+// DetectCompression returns the compression format of a file, or
+// CompressionNone for uncompressed files.
+func DetectCompression(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return CompressionNone, err
+	}
+	defer file.Close()
+
+	magic := make([]byte, 3)
+	n, err := io.ReadFull(file, magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return CompressionNone, err
+	}
+	return compression(magic[:n]), nil
+}
+
+// decompressingFile closes the underlying file
+type decompressingFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (f *decompressingFile) Close() error {
+	return f.file.Close()
+}
+
+// OpenFile opens a file for reading, compressed files are decompressed
+// transparently, cf. Decompress. Offsets in the returned stream refer to
+// the uncompressed data.
+func OpenFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := Decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return &decompressingFile{Reader: r, file: file}, nil
+}
@@ -0,0 +1,61 @@
+package marctools
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestOpenFile(t *testing.T) {
+	var tests = []struct {
+		in          string
+		compression string
+		count       int64
+	}{
+		{"./fixtures/journals.mrc", CompressionNone, 10},
+		{"./fixtures/journals.mrc.gz", CompressionGzip, 10},
+		{"./fixtures/journals.mrc.bz2", CompressionBzip2, 10},
+	}
+
+	for _, tt := range tests {
+		c, err := DetectCompression(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != tt.compression {
+			t.Errorf("DetectCompression(%s) => %q, want: %q", tt.in, c, tt.compression)
+		}
+		file, err := OpenFile(tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count, err := CountRecords(file)
+		if err != nil {
+			t.Errorf("CountRecords(%s) => %s", tt.in, err)
+		}
+		if count != tt.count {
+			t.Errorf("CountRecords(%s) => %d, want: %d", tt.in, count, tt.count)
+		}
+		if err := file.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	var tests = []string{"", "a", "BZ", "plain text"}
+	for _, in := range tests {
+		r, err := Decompress(strings.NewReader(in))
+		if err != nil {
+			t.Errorf("Decompress(%q) => %s", in, err)
+			continue
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(b) != in {
+			t.Errorf("Decompress(%q) => %q", in, b)
+		}
+	}
+}
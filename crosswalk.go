@@ -0,0 +1,169 @@
+package marctools
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// Helpers for the crosswalks from MARC 21 to other metadata formats, cf.
+// MarshalDC and MarshalMODS. The mappings follow the Library of Congress
+// stylesheets, e.g. MARC21slim2OAIDC.xsl, for the fields covered.
+
+// element is a node of a generated XML document
+type element struct {
+	name     string
+	attrs    []string // name and value pairs
+	text     string
+	children []*element
+}
+
+// add appends a child element with the given text and attributes, given as
+// name and value pairs, and returns it
+func (e *element) add(name, text string, attrs ...string) *element {
+	child := &element{name: name, text: text, attrs: attrs}
+	e.children = append(e.children, child)
+	return child
+}
+
+// empty reports whether an element has neither text nor non-empty children
+func (e *element) empty() bool {
+	if e.text != "" {
+		return false
+	}
+	for _, c := range e.children {
+		if !c.empty() {
+			return false
+		}
+	}
+	return true
+}
+
+// write serializes the element, omitting empty elements
+func (e *element) write(buf *bytes.Buffer, indent bool, level int) {
+	if level > 0 && e.empty() {
+		return
+	}
+	if indent && level > 0 {
+		buf.WriteString("\n")
+		buf.WriteString(strings.Repeat("  ", level))
+	}
+	buf.WriteString("<" + e.name)
+	for i := 0; i+1 < len(e.attrs); i += 2 {
+		if e.attrs[i+1] == "" {
+			continue
+		}
+		buf.WriteString(" " + e.attrs[i] + `="`)
+		xmlText(buf, e.attrs[i+1])
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+	xmlText(buf, e.text)
+	for _, c := range e.children {
+		c.write(buf, indent, level+1)
+	}
+	if indent && len(e.children) > 0 {
+		buf.WriteString("\n")
+		buf.WriteString(strings.Repeat("  ", level))
+	}
+	buf.WriteString("</" + e.name + ">")
+}
+
+// bytes returns the serialized element
+func (e *element) bytes(indent bool) []byte {
+	var buf bytes.Buffer
+	e.write(&buf, indent, 0)
+	return buf.Bytes()
+}
+
+// chopPunctuation removes trailing punctuation, as ISBD punctuation is of no
+// use outside of MARC
+func chopPunctuation(s string) string {
+	return strings.TrimRight(strings.TrimSpace(s), " .,:;/")
+}
+
+// subfieldsText joins the values of the subfields with the given codes, in
+// the order of the field, separated by spaces
+func subfieldsText(field marc22.DataField, codes string) string {
+	var values []string
+	for _, subfield := range field.SubFields {
+		if subfield.Code != "" && strings.Contains(codes, subfield.Code) {
+			if v := strings.TrimSpace(subfield.Value); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// subfieldValues returns the trimmed, non-empty values of the subfields with
+// the given code
+func subfieldValues(field marc22.DataField, code string) []string {
+	var values []string
+	for _, subfield := range field.SubFields {
+		if subfield.Code == code {
+			if v := strings.TrimSpace(subfield.Value); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// firstSubfield returns the first value of a subfield or the empty string
+func firstSubfield(field marc22.DataField, code string) string {
+	if values := subfieldValues(field, code); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// controlFieldValue returns the value of the first control field with the
+// given tag or the empty string
+func controlFieldValue(record *marc22.Record, tag string) string {
+	if fields := record.GetControlFields(tag); len(fields) > 0 {
+		return fields[0].Data
+	}
+	return ""
+}
+
+// fixedField returns the characters from start to end of a control field,
+// e.g. the language in 008/35-37, or the empty string, if the field is too
+// short or the value is blank or fill characters
+func fixedField(record *marc22.Record, tag string, start, end int) string {
+	data := controlFieldValue(record, tag)
+	if len(data) < end {
+		return ""
+	}
+	return strings.Trim(data[start:end], " |")
+}
+
+// leaderByte returns a single position of the leader
+func leaderByte(record *marc22.Record, i int) byte {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return ' '
+	}
+	return leader[i]
+}
+
+// isTag reports whether a tag is one of the given tags
+func isTag(tag string, tags ...string) bool {
+	for _, t := range tags {
+		if tag == t {
+			return true
+		}
+	}
+	return false
+}
+
+// isRelatedEntry reports whether a tag is a linking entry, 760-787
+func isRelatedEntry(tag string) bool {
+	return tag >= "760" && tag <= "787"
+}
+
+// isNote reports whether a tag is a note, 5XX
+func isNote(tag string) bool {
+	return len(tag) == 3 && tag[0] == '5'
+}
@@ -0,0 +1,136 @@
+package marctools
+
+import (
+	"encoding/xml"
+
+	"github.com/miku/marc22"
+)
+
+// Namespaces of simple Dublin Core, as used by OAI-PMH (oai_dc)
+const (
+	OAIDCNamespace = "http://www.openarchives.org/OAI/2.0/oai_dc/"
+	DCNamespace    = "http://purl.org/dc/elements/1.1/"
+	xsiNamespace   = "http://www.w3.org/2001/XMLSchema-instance"
+	oaiDCSchema    = OAIDCNamespace + " http://www.openarchives.org/OAI/2.0/oai_dc.xsd"
+)
+
+// Start and end of a collection of oai_dc records, as written by the LoC
+// stylesheet for MARCXML collections.
+const (
+	DCCollectionStart = xml.Header + `<oai_dc:dcCollection xmlns:oai_dc="` + OAIDCNamespace +
+		`" xmlns:dc="` + DCNamespace + `" xmlns:xsi="` + xsiNamespace + `">` + "\n"
+	DCCollectionEnd = "</oai_dc:dcCollection>\n"
+)
+
+// alphabetic subfield codes, numeric codes hold control data, e.g. $0 or $6
+const alphabetic = "abcdefghijklmnopqrstuvwxyz"
+
+// dcTypes maps leader/06 to dc:type, cf. MARC21slim2OAIDC.xsl
+var dcTypes = map[byte]string{
+	'a': "text",
+	't': "text",
+	'e': "cartographic",
+	'f': "cartographic",
+	'c': "notated music",
+	'd': "notated music",
+	'i': "sound recording",
+	'j': "sound recording",
+	'k': "still image",
+	'g': "moving image",
+	'r': "three dimensional object",
+	'm': "software, multimedia",
+	'p': "mixed material",
+}
+
+// dcElements are written in this order, as in MARC21slim2OAIDC.xsl
+var dcElements = []string{"title", "creator", "type", "publisher", "date", "language",
+	"format", "description", "subject", "coverage", "relation", "identifier", "rights"}
+
+// dcSubjectCodes are the subfields used for subjects, by tag
+var dcSubjectCodes = map[string]string{
+	"600": "abcdq",
+	"610": "abdq",
+	"611": "acdeq",
+	"630": "adfhklor",
+	"650": "ae",
+	"653": "a",
+}
+
+// MarshalDC serializes a record to a simple Dublin Core oai_dc:dc element,
+// following the LoC MARC to Dublin Core crosswalk, with namespaces declared,
+// so it can be used as OAI-PMH metadata as is. Besides 260, publisher and
+// date are taken from 264 (publication), the extent from 300 is added as
+// format and geographic subjects (651) as coverage.
+func MarshalDC(record *marc22.Record, indent bool) ([]byte, error) {
+	if _, err := leaderBytes(record); err != nil {
+		return nil, err
+	}
+	dc := &element{name: "oai_dc:dc", attrs: []string{
+		"xmlns:oai_dc", OAIDCNamespace,
+		"xmlns:dc", DCNamespace,
+		"xmlns:xsi", xsiNamespace,
+		"xsi:schemaLocation", oaiDCSchema,
+	}}
+	values := make(map[string][]string)
+	add := func(name string, v ...string) {
+		values[name] = append(values[name], v...)
+	}
+	for _, field := range record.DataFields {
+		switch tag := field.Tag; {
+		case tag == "245":
+			add("title", subfieldsText(field, "abfghk"))
+		case isTag(tag, "100", "110", "111", "700", "710", "711", "720"):
+			add("creator", subfieldsText(field, alphabetic))
+		case tag == "655":
+			add("type", subfieldsText(field, alphabetic))
+		case tag == "260" || tag == "264" && field.Ind2 == "1":
+			add("publisher", subfieldsText(field, "ab"))
+			add("date", subfieldValues(field, "c")...)
+		case tag == "300":
+			add("format", subfieldsText(field, "abcefg"))
+		case tag == "856":
+			add("format", subfieldValues(field, "q")...)
+			add("identifier", subfieldValues(field, "u")...)
+		case tag == "506" || tag == "540":
+			add("rights", subfieldValues(field, "a")...)
+		case tag == "530":
+			add("relation", subfieldsText(field, "abcdu"))
+		case isNote(tag) && tag != "546":
+			add("description", subfieldValues(field, "a")...)
+		case dcSubjectCodes[tag] != "":
+			add("subject", subfieldsText(field, dcSubjectCodes[tag]))
+		case tag == "651":
+			add("coverage", subfieldsText(field, "a"))
+		case tag == "662" || tag == "752":
+			add("coverage", subfieldsText(field, "abcdfgh"))
+		case isRelatedEntry(tag):
+			add("relation", subfieldsText(field, "ot"))
+		case tag == "020":
+			for _, isbn := range subfieldValues(field, "a") {
+				add("identifier", "URN:ISBN:"+isbn)
+			}
+		}
+	}
+	add("language", fixedField(record, "008", 35, 38))
+
+	for _, name := range dcElements {
+		if name == "type" {
+			// the type of resource comes from the leader, the stylesheet
+			// flags collections and manuscripts with attributes
+			leader6, leader7 := leaderByte(record, 6), leaderByte(record, 7)
+			var collection, manuscript string
+			if leader7 == 'c' {
+				collection = "yes"
+			}
+			switch leader6 {
+			case 'd', 'f', 'p', 't':
+				manuscript = "yes"
+			}
+			dc.add("dc:type", dcTypes[leader6], "collection", collection, "manuscript", manuscript)
+		}
+		for _, v := range values[name] {
+			dc.add("dc:"+name, v)
+		}
+	}
+	return dc.bytes(indent), nil
+}
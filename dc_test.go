@@ -0,0 +1,97 @@
+package marctools
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+var crosswalkTestRecord = &marc22.Record{
+	Leader: "00000nam a2200000 a 4500",
+	ControlFields: []marc22.ControlField{
+		{Tag: "001", Data: "123"},
+		{Tag: "008", Data: "150101s2015    gw            000 0 ger d"},
+	},
+	DataFields: []marc22.DataField{
+		{Tag: "020", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{{Code: "a", Value: "9783161484100"}}},
+		{Tag: "100", Ind1: "1", Ind2: " ", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Goethe, Johann Wolfgang von,"},
+			{Code: "d", Value: "1749-1832."},
+			{Code: "e", Value: "author."},
+		}},
+		{Tag: "245", Ind1: "1", Ind2: "4", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Die Leiden des jungen Werthers :"},
+			{Code: "b", Value: "Roman /"},
+			{Code: "c", Value: "Goethe."},
+		}},
+		{Tag: "264", Ind1: " ", Ind2: "1", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Leipzig :"},
+			{Code: "b", Value: "Weygand,"},
+			{Code: "c", Value: "2015."},
+		}},
+		{Tag: "300", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{{Code: "a", Value: "224 S. ;"}, {Code: "c", Value: "19 cm"}}},
+		{Tag: "500", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{{Code: "a", Value: "Briefroman."}}},
+		{Tag: "650", Ind1: " ", Ind2: "0", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Suicide"},
+			{Code: "v", Value: "Fiction."},
+		}},
+		{Tag: "651", Ind1: " ", Ind2: "0", SubFields: []*marc22.SubField{{Code: "a", Value: "Germany"}}},
+		{Tag: "856", Ind1: "4", Ind2: "0", SubFields: []*marc22.SubField{
+			{Code: "u", Value: "http://example.org/werther?a=1&b=2"},
+			{Code: "q", Value: "application/pdf"},
+		}},
+	},
+}
+
+func TestMarshalDC(t *testing.T) {
+	b, err := MarshalDC(crosswalkTestRecord, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dc struct {
+		XMLName xml.Name
+		Fields  []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal(b, &dc); err != nil {
+		t.Fatalf("MarshalDC: %s: %s", err, b)
+	}
+	if dc.XMLName.Space != OAIDCNamespace || dc.XMLName.Local != "dc" {
+		t.Errorf("MarshalDC: got root element %v", dc.XMLName)
+	}
+	var got [][2]string
+	for _, f := range dc.Fields {
+		if f.XMLName.Space != DCNamespace {
+			t.Errorf("MarshalDC: got namespace %s for %s", f.XMLName.Space, f.XMLName.Local)
+		}
+		got = append(got, [2]string{f.XMLName.Local, f.Value})
+	}
+	want := [][2]string{
+		{"title", "Die Leiden des jungen Werthers : Roman /"},
+		{"creator", "Goethe, Johann Wolfgang von, 1749-1832. author."},
+		{"type", "text"},
+		{"publisher", "Leipzig : Weygand,"},
+		{"date", "2015."},
+		{"language", "ger"},
+		{"format", "224 S. ; 19 cm"},
+		{"format", "application/pdf"},
+		{"description", "Briefroman."},
+		{"subject", "Suicide"},
+		{"coverage", "Germany"},
+		{"identifier", "URN:ISBN:9783161484100"},
+		{"identifier", "http://example.org/werther?a=1&b=2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MarshalDC => %v, want: %v", got, want)
+	}
+}
+
+func TestMarshalDCInvalidLeader(t *testing.T) {
+	if _, err := MarshalDC(&marc22.Record{}, false); err == nil {
+		t.Errorf("MarshalDC(record without leader) => nil error")
+	}
+}
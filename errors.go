@@ -0,0 +1,89 @@
+package marctools
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrMissingIdentifier is returned, if a record has no identifier (001)
+	ErrMissingIdentifier = errors.New("missing identifier")
+	// ErrInvalidLeader is returned, if a leader cannot be parsed
+	ErrInvalidLeader = errors.New("invalid leader")
+	// ErrUnknownTag is returned, if a tag specification cannot be interpreted
+	ErrUnknownTag = errors.New("unknown tag")
+	// ErrInvalidIdentifierSpec is returned, if an identifier specification cannot be parsed
+	ErrInvalidIdentifierSpec = errors.New("invalid identifier specification")
+	// ErrInvalidMetaSpec is returned, if a meta field specification cannot be parsed
+	ErrInvalidMetaSpec = errors.New("invalid meta specification")
+	// ErrInvalidSelector is returned, if a field selector cannot be parsed
+	ErrInvalidSelector = errors.New("invalid selector")
+	// ErrIdentifierCount is returned, if the number of identifiers and records differ
+	ErrIdentifierCount = errors.New("number of identifiers and records differ")
+	// ErrInvalidRecord is returned, if a record cannot be parsed
+	ErrInvalidRecord = errors.New("invalid record")
+	// ErrInvalidMARC8 is returned, if bytes cannot be decoded as MARC-8
+	ErrInvalidMARC8 = errors.New("invalid MARC-8")
+	// ErrUnknownCharset is returned for unsupported character set names
+	ErrUnknownCharset = errors.New("unknown charset")
+	// ErrFieldTooLong is returned, if a field exceeds 9999 bytes in ISO 2709
+	ErrFieldTooLong = errors.New("field too long")
+	// ErrRecordTooLong is returned, if a record exceeds 99999 bytes in ISO 2709
+	ErrRecordTooLong = errors.New("record too long")
+	// ErrAmbiguousOrder is returned, if the order of fields or subfields cannot be restored
+	ErrAmbiguousOrder = errors.New("ambiguous order")
+)
+
+// RecordError wraps an error that occured while processing a single record.
+// Use errors.Is or errors.As to inspect the underlying error.
+type RecordError struct {
+	Filename string // may be empty, e.g. for standard input
+	Index    int64  // zero-based number of the record in the input
+	Offset   int64  // byte offset of the record, -1 if unknown
+	Line     int    // line number of the record in text formats, 0 if unknown
+	Err      error
+}
+
+// Error returns a message containing all available location information.
+func (e *RecordError) Error() string {
+	var loc string
+	if e.Filename != "" {
+		loc = e.Filename + ": "
+	}
+	loc = fmt.Sprintf("%srecord %d", loc, e.Index)
+	if e.Line > 0 {
+		loc = fmt.Sprintf("%s at line %d", loc, e.Line)
+	}
+	if e.Offset >= 0 {
+		loc = fmt.Sprintf("%s at offset %d", loc, e.Offset)
+	}
+	return fmt.Sprintf("%s: %s", loc, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// SkipError reports a range of bytes, that has been skipped while
+// resynchronizing a Reader.
+type SkipError struct {
+	Filename string // may be empty, e.g. for standard input
+	Offset   int64  // start of the skipped range
+	Length   int64  // number of bytes skipped
+	Err      error  // the reason for skipping
+}
+
+// Error returns the skipped byte range and the reason.
+func (e *SkipError) Error() string {
+	var loc string
+	if e.Filename != "" {
+		loc = e.Filename + ": "
+	}
+	return fmt.Sprintf("%sskipped %d bytes at offset %d: %s", loc, e.Length, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SkipError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,106 @@
+//go:build ignore
+// +build ignore
+
+// gen_marc8tables writes marc8_tables.go with the MARC-8 sets, that are not
+// built into marc8.go, from the Library of Congress code tables:
+//
+//	$ curl -sO http://www.loc.gov/marc/specifications/codetables.xml
+//	$ go run gen_marc8tables.go codetables.xml
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// sets to generate by ISO code, with the name of the final constant
+var sets = []struct {
+	iso       string
+	final     string
+	name      string
+	multibyte bool
+}{
+	{"34", "finalExtArabic", "Extended Arabic", false},
+	{"31", "finalEACC", "East Asian (EACC)", true},
+}
+
+type codeTable struct {
+	Name    string `xml:"name,attr"`
+	ISOCode string `xml:"ISOcode,attr"`
+	Codes   []struct {
+		MARC string `xml:"marc"`
+		UCS  string `xml:"ucs"`
+		Alt  string `xml:"alt"`
+	} `xml:"code"`
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: go run gen_marc8tables.go codetables.xml")
+	}
+	b, err := ioutil.ReadFile(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	var doc struct {
+		Tables []codeTable `xml:"codeTable>characterSet"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		log.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by gen_marc8tables.go from the LoC code tables; DO NOT EDIT.\n\n")
+	buf.WriteString("package marctools\n\nfunc init() {\n")
+	for _, set := range sets {
+		codes := make(map[int]rune)
+		for _, table := range doc.Tables {
+			if table.ISOCode != set.iso {
+				continue
+			}
+			for _, c := range table.Codes {
+				ucs := c.UCS
+				if ucs == "" {
+					ucs = c.Alt
+				}
+				code, err := strconv.ParseUint(c.MARC, 16, 32)
+				if err != nil {
+					log.Fatalf("%s: invalid code: %q", table.Name, c.MARC)
+				}
+				r, err := strconv.ParseUint(ucs, 16, 32)
+				if err != nil {
+					log.Fatalf("%s: invalid ucs: %q", table.Name, ucs)
+				}
+				// strip the high bit of each byte, as LoadMARC8Tables does
+				codes[int(code&0x7F7F7F)] = rune(r)
+			}
+		}
+		if len(codes) == 0 {
+			log.Fatalf("no codes for %s", set.name)
+		}
+		keys := make([]int, 0, len(codes))
+		for k := range codes {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		fmt.Fprintf(&buf, "\tregisterMARC8Set(%q, %s, %v, map[int]rune{\n", set.name, set.final, set.multibyte)
+		for _, k := range keys {
+			fmt.Fprintf(&buf, "\t\t0x%X: 0x%04X,\n", k, codes[k])
+		}
+		buf.WriteString("\t})\n")
+	}
+	buf.WriteString("}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ioutil.WriteFile("marc8_tables.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
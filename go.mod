@@ -1,6 +1,6 @@
 module github.com/ubleipzig/marctools
 
-go 1.12
+go 1.13
 
 require (
 	github.com/mattn/go-sqlite3 v1.14.27
@@ -0,0 +1,205 @@
+package marctools
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// IdentifierField is a single part of an identifier
+type IdentifierField struct {
+	Tag    string // e.g. 001 or 035
+	Code   string // subfield code, empty for control fields
+	Prefix string // if not empty, only values with this prefix are used
+}
+
+// String returns the field in the notation used by ParseIdentifierSpec.
+func (f IdentifierField) String() string {
+	s := f.Tag
+	if f.Code != "" {
+		s += "." + f.Code
+	}
+	if f.Prefix != "" {
+		s += "=" + f.Prefix
+	}
+	return s
+}
+
+// IdentifierSpec describes how the identifier of a record is built. The
+// first matching value of each field is used, values of multiple fields are
+// joined with a separator. A record lacking any of the fields has no
+// identifier. Identifiers are always trimmed.
+type IdentifierSpec struct {
+	Fields      []IdentifierField
+	Separator   string // used to join the values of multiple fields
+	StripPrefix bool   // remove the prefix of a field from its value
+	Lowercase   bool   // fold identifiers to lower case
+}
+
+// DefaultIdentifierSpec uses the first 001 field as identifier.
+var DefaultIdentifierSpec = &IdentifierSpec{Fields: []IdentifierField{{Tag: "001"}}}
+
+// ParseIdentifierSpec parses an identifier specification. Fields are given
+// as tag (control fields) or tag.code (subfields), optionally followed by
+// =prefix to only use values starting with prefix. Multiple fields are
+// concatenated with +. Options follow after a semicolon: lower (case
+// folding), strip (remove prefixes from values) and sep=... (separator for
+// concatenated values). Examples: "001", "003+001;sep=:",
+// "035.a=(DE-576);strip".
+func ParseIdentifierSpec(s string) (*IdentifierSpec, error) {
+	parts := strings.Split(s, ";")
+	spec := &IdentifierSpec{}
+	for _, p := range strings.Split(parts[0], "+") {
+		var field IdentifierField
+		if i := strings.Index(p, "="); i >= 0 {
+			p, field.Prefix = p[:i], p[i+1:]
+		}
+		p = strings.TrimSpace(p)
+		switch {
+		case regexControlfield.MatchString(p) && strings.HasPrefix(p, "00"):
+			field.Tag = p
+		case regexSubfield.MatchString(p) && !strings.HasPrefix(p, "00"):
+			field.Tag, field.Code = p[:3], p[4:]
+		default:
+			return nil, fmt.Errorf("%w: %q: expected control field or subfield, got %q", ErrInvalidIdentifierSpec, s, p)
+		}
+		spec.Fields = append(spec.Fields, field)
+	}
+	for _, option := range parts[1:] {
+		switch {
+		case option == "lower":
+			spec.Lowercase = true
+		case option == "strip":
+			spec.StripPrefix = true
+		case strings.HasPrefix(option, "sep="):
+			spec.Separator = strings.TrimPrefix(option, "sep=")
+		default:
+			return nil, fmt.Errorf("%w: %q: unknown option %q", ErrInvalidIdentifierSpec, s, option)
+		}
+	}
+	return spec, nil
+}
+
+// String returns the specification in the notation used by
+// ParseIdentifierSpec.
+func (spec *IdentifierSpec) String() string {
+	var fields []string
+	for _, f := range spec.Fields {
+		fields = append(fields, f.String())
+	}
+	s := strings.Join(fields, "+")
+	if spec.Lowercase {
+		s += ";lower"
+	}
+	if spec.StripPrefix {
+		s += ";strip"
+	}
+	if spec.Separator != "" {
+		s += ";sep=" + spec.Separator
+	}
+	return s
+}
+
+// Normalize applies trimming and case folding to a value. Use it to compare
+// externally supplied identifiers with those extracted from records.
+func (spec *IdentifierSpec) Normalize(s string) string {
+	s = strings.TrimSpace(s)
+	if spec.Lowercase {
+		s = strings.ToLower(s)
+	}
+	return s
+}
+
+// match returns the value, if it matches the prefix of the field
+func (spec *IdentifierSpec) match(f IdentifierField, value string) (string, bool) {
+	if !strings.HasPrefix(value, f.Prefix) {
+		return "", false
+	}
+	if spec.StripPrefix {
+		value = strings.TrimPrefix(value, f.Prefix)
+	}
+	return value, true
+}
+
+// join builds the identifier from the values of all fields
+func (spec *IdentifierSpec) join(values []string) string {
+	return spec.Normalize(strings.Join(values, spec.Separator))
+}
+
+// Identifier returns the identifier of a parsed record or ErrMissingIdentifier.
+func (spec *IdentifierSpec) Identifier(record *marc22.Record) (string, error) {
+	var values []string
+	for _, f := range spec.Fields {
+		value, ok := spec.fieldValue(record, f)
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingIdentifier, f)
+		}
+		values = append(values, value)
+	}
+	return spec.join(values), nil
+}
+
+// fieldValue returns the first matching value of a field in a parsed record
+func (spec *IdentifierSpec) fieldValue(record *marc22.Record, f IdentifierField) (string, bool) {
+	if f.Code == "" {
+		for _, field := range record.GetControlFields(f.Tag) {
+			if value, ok := spec.match(f, strings.TrimSpace(field.Data)); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+	for _, subfield := range record.GetSubFields(f.Tag, f.Code) {
+		if value, ok := spec.match(f, strings.TrimSpace(subfield.Value)); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// rawIdentifier returns the identifier of a raw record, using only the
+// directory and the fields in question.
+func (spec *IdentifierSpec) rawIdentifier(data []byte) (string, error) {
+	var values []string
+	for _, f := range spec.Fields {
+		value, ok, err := spec.rawFieldValue(data, f)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrMissingIdentifier, f)
+		}
+		values = append(values, value)
+	}
+	return spec.join(values), nil
+}
+
+// rawFieldValue returns the first matching value of a field in a raw record
+func (spec *IdentifierSpec) rawFieldValue(data []byte, f IdentifierField) (string, bool, error) {
+	fields, err := rawFields(data, f.Tag)
+	if err != nil {
+		return "", false, err
+	}
+	for _, field := range fields {
+		if f.Code == "" {
+			if value, ok := spec.match(f, string(bytes.TrimSpace(field))); ok {
+				return value, true, nil
+			}
+			continue
+		}
+		if len(field) < 2 {
+			continue
+		}
+		for _, chunk := range bytes.Split(field[2:], []byte{marc22.DELIM})[1:] {
+			if len(chunk) == 0 || string(chunk[0:1]) != f.Code {
+				continue
+			}
+			if value, ok := spec.match(f, string(bytes.TrimSpace(chunk[1:]))); ok {
+				return value, true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
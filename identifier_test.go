@@ -0,0 +1,117 @@
+package marctools
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+func TestParseIdentifierSpec(t *testing.T) {
+	var tests = []struct {
+		in   string
+		spec *IdentifierSpec
+		err  error
+	}{
+		{"001", DefaultIdentifierSpec, nil},
+		{"003+001;sep=:", &IdentifierSpec{
+			Fields:    []IdentifierField{{Tag: "003"}, {Tag: "001"}},
+			Separator: ":"}, nil},
+		{"035.a=(DE-576);lower;strip", &IdentifierSpec{
+			Fields:      []IdentifierField{{Tag: "035", Code: "a", Prefix: "(DE-576)"}},
+			StripPrefix: true,
+			Lowercase:   true}, nil},
+		{"", nil, ErrInvalidIdentifierSpec},
+		{"035", nil, ErrInvalidIdentifierSpec},
+		{"001.a", nil, ErrInvalidIdentifierSpec},
+		{"001;upper", nil, ErrInvalidIdentifierSpec},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseIdentifierSpec(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseIdentifierSpec(%q) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if !reflect.DeepEqual(spec, tt.spec) {
+			t.Errorf("ParseIdentifierSpec(%q) => %+v, want: %+v", tt.in, spec, tt.spec)
+		}
+		if err == nil && spec.String() != tt.in {
+			t.Errorf("ParseIdentifierSpec(%q).String() => %q", tt.in, spec.String())
+		}
+	}
+}
+
+func TestIdentifierSpecIdentifiers(t *testing.T) {
+	var tests = []struct {
+		spec string
+		in   string
+		out  []string
+		err  error
+	}{
+		{"003+001;sep=:", "./fixtures/issue-5.mrc", []string{
+			"SIRSI:u1033898", "SIRSI:u1033899", "SIRSI:u1033900"}, nil},
+		{"003+001", "./fixtures/journals.mrc", nil, ErrMissingIdentifier},
+		{"035.a=(IeDuNL);strip", "./fixtures/authoritybibs.mrc", []string{
+			"29995", "29994", "29993", "29990", "12895", "30492", "30147", "23438", "1048"}, nil},
+		{"003+001;sep=:;lower", "./fixtures/authoritybibs.mrc", []string{
+			"iedunl:vtls000013187", "iedunl:vtls000013186", "iedunl:vtls000013185",
+			"iedunl:vtls000013184", "iedunl:vtls000003884", "iedunl:vtls000013412",
+			"iedunl:vtls000013282", "iedunl:vtls000009763", "iedunl:vtls000000329"}, nil},
+		{"035.a=(OCoLC)", "./fixtures/weird_ids.mrc", nil, ErrMissingIdentifier},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseIdentifierSpec(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, safe := range []bool{false, true} {
+			ids, err := spec.Identifiers(tt.in, safe)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Identifiers(%s, %s, %v) => %v, want: %v", tt.spec, tt.in, safe, err, tt.err)
+			}
+			if !reflect.DeepEqual(ids, tt.out) {
+				t.Errorf("Identifiers(%s, %s, %v) => %q, want: %q", tt.spec, tt.in, safe, ids, tt.out)
+			}
+		}
+	}
+}
+
+func BenchmarkIdentifierSpecIdentifier(b *testing.B) {
+	spec, err := ParseIdentifierSpec("035.a=(IeDuNL);strip")
+	if err != nil {
+		b.Fatal(err)
+	}
+	file, err := os.Open("./fixtures/authoritybibs.mrc")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+	record, err := marc22.ReadRecord(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := spec.Identifier(record); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWalkRecords(b *testing.B) {
+	spec, err := ParseIdentifierSpec("003+001;sep=:;lower")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		err := spec.WalkRecords("./fixtures/authoritybibs.mrc", false, func(id string, rr *RawRecord) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
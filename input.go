@@ -0,0 +1,124 @@
+package marctools
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Stdin is the filename, that denotes standard input
+const Stdin = "-"
+
+// ExpandPatterns expands glob patterns into a list of filenames. Arguments
+// without glob characters and Stdin are kept as they are. A pattern, that
+// does not match any file, is an error.
+func ExpandPatterns(args []string) ([]string, error) {
+	var filenames []string
+	for _, arg := range args {
+		if arg == Stdin {
+			filenames = append(filenames, arg)
+			continue
+		}
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			if _, err := os.Stat(arg); err != nil {
+				return nil, err
+			}
+			matches = []string{arg}
+		}
+		filenames = append(filenames, matches...)
+	}
+	return filenames, nil
+}
+
+// Inputs returns the input filenames for the given command line arguments,
+// cf. ExpandPatterns. Without arguments, standard input is read.
+func Inputs(args []string) ([]string, error) {
+	if len(args) == 0 {
+		return []string{Stdin}, nil
+	}
+	return ExpandPatterns(args)
+}
+
+// OpenInput opens a file for reading, like OpenFile. Stdin denotes standard
+// input, which is decompressed transparently as well.
+func OpenInput(filename string) (io.ReadCloser, error) {
+	if filename != Stdin {
+		return OpenFile(filename)
+	}
+	r, err := Decompress(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+// MultiReader reads binary MARC records from a number of files, one after
+// another, as a single stream. Index and offset of the records refer to the
+// file given in the Filename field of the record.
+type MultiReader struct {
+	// Resync enables recovery from corrupt records, cf. Reader.
+	Resync bool
+
+	filenames []string
+	reader    *Reader
+	file      io.ReadCloser
+	err       error
+}
+
+// NewMultiReader returns a new reader, that reads records from the given
+// files in order. Stdin denotes standard input.
+func NewMultiReader(filenames []string) *MultiReader {
+	return &MultiReader{filenames: filenames}
+}
+
+// Next returns the next record or io.EOF, if all files are exhausted. Errors
+// are handled like in Reader.Next, a failure to open a file stops reading.
+func (r *MultiReader) Next() (*RawRecord, error) {
+	for r.err == nil {
+		if r.reader == nil {
+			if len(r.filenames) == 0 {
+				r.err = io.EOF
+				break
+			}
+			filename := r.filenames[0]
+			r.filenames = r.filenames[1:]
+			file, err := OpenInput(filename)
+			if err != nil {
+				r.err = err
+				break
+			}
+			r.file = file
+			r.reader = NewReader(file)
+			r.reader.Filename = filename
+			r.reader.Resync = r.Resync
+		}
+		rr, err := r.reader.Next()
+		if err == io.EOF {
+			r.err = r.closeFile()
+			continue
+		}
+		return rr, err
+	}
+	return nil, r.err
+}
+
+// closeFile closes the current file
+func (r *MultiReader) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file, r.reader = nil, nil
+	return err
+}
+
+// Close closes the file currently read.
+func (r *MultiReader) Close() error {
+	return r.closeFile()
+}
@@ -0,0 +1,79 @@
+package marctools
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExpandPatterns(t *testing.T) {
+	var tests = []struct {
+		in  []string
+		out []string
+		err error
+	}{
+		{[]string{"-"}, []string{"-"}, nil},
+		{[]string{"./fixtures/j*.mrc", "-"}, []string{"fixtures/journals.mrc", "-"}, nil},
+		{[]string{"./fixtures/journals.mrc*"}, []string{
+			"fixtures/journals.mrc", "fixtures/journals.mrc.bz2", "fixtures/journals.mrc.gz"}, nil},
+		{[]string{"./fixtures/does-not-exist.mrc"}, nil, os.ErrNotExist},
+	}
+	for _, tt := range tests {
+		out, err := ExpandPatterns(tt.in)
+		if !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("ExpandPatterns(%v) => %v, want: %v", tt.in, out, tt.out)
+		}
+		if (err == nil) != (tt.err == nil) || (err != nil && !os.IsNotExist(err)) {
+			t.Errorf("ExpandPatterns(%v) => %v, want: %v", tt.in, err, tt.err)
+		}
+	}
+}
+
+func TestMultiReader(t *testing.T) {
+	filenames := []string{"./fixtures/deweybrowse.mrc", "./fixtures/journals.mrc.gz", "./fixtures/heb.mrc"}
+	reader := NewMultiReader(filenames)
+	defer reader.Close()
+
+	type position struct {
+		Filename string
+		Index    int64
+		Offset   int64
+	}
+	var positions []position
+	for {
+		rr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		positions = append(positions, position{rr.Filename, rr.Index, rr.Offset})
+	}
+	if len(positions) != 12 {
+		t.Fatalf("MultiReader(%v) => %d records, want: 12", filenames, len(positions))
+	}
+	var tests = []struct {
+		i   int
+		out position
+	}{
+		{0, position{"./fixtures/deweybrowse.mrc", 0, 0}},
+		{1, position{"./fixtures/journals.mrc.gz", 0, 0}},
+		{2, position{"./fixtures/journals.mrc.gz", 1, 1571}},
+		{11, position{"./fixtures/heb.mrc", 0, 0}},
+	}
+	for _, tt := range tests {
+		if positions[tt.i] != tt.out {
+			t.Errorf("MultiReader(%v)[%d] => %+v, want: %+v", filenames, tt.i, positions[tt.i], tt.out)
+		}
+	}
+
+	reader = NewMultiReader([]string{"./fixtures/heb.mrc", "./fixtures/does-not-exist.mrc"})
+	if _, err := reader.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); !os.IsNotExist(err) {
+		t.Errorf("Next() => %v, want: not exist error", err)
+	}
+}
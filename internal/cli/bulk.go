@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+
+	"github.com/ubleipzig/marctools"
+)
+
+// defaultRequestSize is the number of records per request, if -url is given
+// without -chunk
+const defaultRequestSize = 1000
+
+// bulkFlags select bulk output for search engines and chunked output
+type bulkFlags struct {
+	format *string
+	index  *string
+	op     *string
+	url    *string
+	chunk  *int
+	id     *identifierFlags
+}
+
+// addBulkFlags registers -bulk, -index, -op, -url, -chunk and -id
+func addBulkFlags(fs *flag.FlagSet) *bulkFlags {
+	return &bulkFlags{
+		format: fs.String("bulk", "", "write bulk requests: es (Elasticsearch bulk API) or solr (JSON update commands)"),
+		index:  fs.String("index", "", "Elasticsearch index of the documents"),
+		op:     fs.String("op", marctools.OpIndex, "Elasticsearch operation: index or create"),
+		url:    fs.String("url", "", "post bulk requests to this URL, e.g. http://localhost:9200/_bulk"),
+		chunk:  fs.Int("chunk", 0, "records per file, named after -o with a number appended, or per request with -url"),
+		id:     addIdentifierFlags(fs, false),
+	}
+}
+
+// options returns the bulk options, or nil, if no bulk format is given
+func (f *bulkFlags) options() (*marctools.BulkOptions, error) {
+	if *f.format == "" {
+		if *f.url != "" {
+			return nil, errors.New("-url requires -bulk")
+		}
+		return nil, nil
+	}
+	spec, err := f.id.parse()
+	if err != nil {
+		return nil, err
+	}
+	options := &marctools.BulkOptions{Format: *f.format, Index: *f.index, Identifier: spec}
+	if *f.format == marctools.BulkElasticsearch {
+		options.OpType = *f.op
+	}
+	return options, options.Check()
+}
+
+// writer returns a chunk writer for the output, which is a single chunk on
+// standard output or in the file given by -o, unless -chunk or -url is set
+func (f *bulkFlags) writer(env *Env) (*marctools.ChunkWriter, error) {
+	if *f.chunk < 0 {
+		return nil, errors.New("-chunk must not be negative")
+	}
+	switch {
+	case *f.url != "":
+		if env.Output != "" {
+			return nil, errors.New("-url and -o are mutually exclusive")
+		}
+		size := *f.chunk
+		if size == 0 {
+			size = defaultRequestSize
+		}
+		return marctools.NewChunkWriter(*f.format, size, marctools.ChunkPoster(http.DefaultClient, *f.url, *f.format)), nil
+	case *f.chunk > 0:
+		if env.Output == "" {
+			return nil, errors.New("-chunk requires -o or -url")
+		}
+		return marctools.NewChunkWriter(*f.format, *f.chunk, marctools.ChunkFiles(env.Output)), nil
+	}
+	w, err := env.Writer()
+	if err != nil {
+		return nil, err
+	}
+	return singleChunkWriter(*f.format, w), nil
+}
+
+// singleChunkWriter writes all records to w, one per line, or as a single
+// object for marctools.BulkSolr
+func singleChunkWriter(format string, w io.Writer) *marctools.ChunkWriter {
+	return marctools.NewChunkWriter(format, 0, func(int) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	})
+}
+
+// nopWriteCloser leaves closing to the owner of the writer, e.g. Env
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ubleipzig/marctools"
+)
+
+// charsetFlags are used by commands, that decode MARC-8
+type charsetFlags struct {
+	charset    *string
+	codeTables *string
+}
+
+// addCharsetFlags registers -charset and -codetables
+func addCharsetFlags(fs *flag.FlagSet) *charsetFlags {
+	return &charsetFlags{
+		charset:    fs.String("charset", marctools.CharsetAuto, "source charset: auto (leader/09), marc8 or utf8"),
+		codeTables: fs.String("codetables", "", "LoC MARC-8 code tables XML, needed for EACC and Extended Arabic"),
+	}
+}
+
+// init checks the charset and reads additional MARC-8 mappings, if given
+func (f *charsetFlags) init() error {
+	if !marctools.IsCharset(*f.charset) {
+		return fmt.Errorf("%w: %s", marctools.ErrUnknownCharset, *f.charset)
+	}
+	if *f.codeTables == "" {
+		return nil
+	}
+	file, err := os.Open(*f.codeTables)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return marctools.LoadMARC8Tables(file)
+}
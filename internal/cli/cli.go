@@ -0,0 +1,226 @@
+// Package cli implements the marctools commands. All commands share a set of
+// options, cf. Options, and are available as subcommands of the marctools
+// binary as well as under their traditional names (marctojson, ...).
+package cli
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+
+	"github.com/ubleipzig/marctools"
+)
+
+// Shared options a command may use, besides -v, -cpuprofile and -verbose,
+// which every command has.
+const (
+	UsesWorkers = 1 << iota
+	UsesOutput
+	UsesIgnoreErrors
+)
+
+// Options are shared by all commands.
+type Options struct {
+	Version      bool
+	CPUProfile   string
+	Verbose      bool
+	Workers      int
+	Output       string
+	IgnoreErrors bool
+}
+
+// Command is a single subcommand.
+type Command struct {
+	Name        string // subcommand name, e.g. tojson
+	Alias       string // traditional binary name, e.g. marctojson
+	Args        string // arguments, as shown in the usage
+	Description string // short description
+	Uses        int    // shared options used, cf. UsesWorkers
+	OutputUsage string // usage of -o, if it is not the default
+	// Setup registers the command specific flags and returns the function,
+	// that runs the command, once the flags are parsed.
+	Setup func(fs *flag.FlagSet) func(env *Env) error
+}
+
+// Env is the environment of a running command.
+type Env struct {
+	Options
+	Args []string
+
+	output *os.File
+	writer *bufio.Writer
+}
+
+// Writer returns a buffered writer to the output file given by -o or to
+// standard output. The file is created on the first call.
+func (env *Env) Writer() (io.Writer, error) {
+	if env.writer != nil {
+		return env.writer, nil
+	}
+	if env.Output == "" {
+		env.writer = bufio.NewWriter(os.Stdout)
+		return env.writer, nil
+	}
+	file, err := os.Create(env.Output)
+	if err != nil {
+		return nil, err
+	}
+	env.output = file
+	env.writer = bufio.NewWriter(file)
+	return env.writer, nil
+}
+
+// Logf logs a message, if -verbose is set.
+func (env *Env) Logf(format string, v ...interface{}) {
+	if env.Verbose {
+		log.Printf(format, v...)
+	}
+}
+
+// close flushes and closes the output
+func (env *Env) close() error {
+	if env.writer == nil {
+		return nil
+	}
+	if err := env.writer.Flush(); err != nil {
+		return err
+	}
+	if env.output != nil {
+		return env.output.Close()
+	}
+	return nil
+}
+
+var commands []*Command
+
+// register adds a command, cf. init functions of the command files
+func register(cmd *Command) {
+	commands = append(commands, cmd)
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+}
+
+// lookup finds a command by name or alias
+func lookup(name string) *Command {
+	for _, cmd := range commands {
+		if cmd.Name == name || cmd.Alias == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// flagSet creates the flag set for a command, with the shared options
+func (cmd *Command) flagSet(name string, opts *Options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.BoolVar(&opts.Version, "v", false, "prints current program version and exit")
+	fs.StringVar(&opts.CPUProfile, "cpuprofile", "", "write cpu profile to file")
+	fs.BoolVar(&opts.Verbose, "verbose", false, "be verbose")
+	if cmd.Uses&UsesWorkers != 0 {
+		fs.IntVar(&opts.Workers, "w", runtime.NumCPU(), "number of workers")
+	}
+	if cmd.Uses&UsesOutput != 0 {
+		usage := cmd.OutputUsage
+		if usage == "" {
+			usage = "output file (or stdout if none given)"
+		}
+		fs.StringVar(&opts.Output, "o", "", usage)
+	}
+	if cmd.Uses&UsesIgnoreErrors != 0 {
+		fs.BoolVar(&opts.IgnoreErrors, "i", false, "ignore marc errors and skip to the next valid record (not recommended)")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [OPTIONS] %s\n", name, cmd.Args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Run parses the arguments and runs the command. The name is used in the
+// usage message.
+func (cmd *Command) Run(name string, args []string) error {
+	var opts Options
+	fs := cmd.flagSet(name, &opts)
+	run := cmd.Setup(fs)
+	fs.Parse(args)
+
+	if opts.Version {
+		fmt.Println(marctools.AppVersion)
+		return nil
+	}
+	if opts.Workers > 0 {
+		runtime.GOMAXPROCS(opts.Workers)
+	}
+	if opts.CPUProfile != "" {
+		f, err := os.Create(opts.CPUProfile)
+		if err != nil {
+			return err
+		}
+		pprof.StartCPUProfile(f)
+		defer pprof.StopCPUProfile()
+	}
+
+	env := &Env{Options: opts, Args: fs.Args()}
+	if err := run(env); err != nil {
+		env.close()
+		if err == flag.ErrHelp {
+			fs.Usage()
+			os.Exit(1)
+		}
+		return err
+	}
+	return env.close()
+}
+
+// Alias runs the command with the given traditional name, e.g. marctojson,
+// with the arguments from the command line. Exits the program on errors.
+func Alias(name string) {
+	cmd := lookup(name)
+	if cmd == nil {
+		log.Fatalf("unknown command: %s", name)
+	}
+	if err := cmd.Run(name, os.Args[1:]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// usage prints all available subcommands
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: marctools COMMAND [OPTIONS] [ARGS]\n\nCommands:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.Name, cmd.Description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun marctools COMMAND -h for the options of a command.\n")
+}
+
+// Main runs the subcommand given on the command line. Exits the program on
+// errors.
+func Main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(1)
+	}
+	name := os.Args[1]
+	switch name {
+	case "-v", "-version", "--version", "version":
+		fmt.Println(marctools.AppVersion)
+		return
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	}
+	cmd := lookup(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage()
+		os.Exit(1)
+	}
+	if err := cmd.Run("marctools "+cmd.Name, os.Args[2:]); err != nil {
+		log.Fatal(err)
+	}
+}
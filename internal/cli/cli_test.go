@@ -0,0 +1,239 @@
+package cli
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ubleipzig/marctools"
+)
+
+// tempDir returns a temporary directory, remove it after the test
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "marctools-cli-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// runCommand runs a command and fails the test, if it does not finish in time
+func runCommand(t *testing.T, name string, args ...string) error {
+	cmd := lookup(name)
+	if cmd == nil {
+		t.Fatalf("unknown command: %s", name)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run(name, args)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s %v did not finish", name, args)
+	}
+	return nil
+}
+
+func TestTruncatedInput(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	b, err := ioutil.ReadFile("../../fixtures/journals.mrc.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.mrc.gz")
+	if err := ioutil.WriteFile(truncated, b[:len(b)*2/3], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// with -i, reading must stop at the end of the stream, too
+	var tests = []struct {
+		name string
+		args []string
+	}{
+		{"marctojson", []string{"-o", filepath.Join(dir, "out.json"), truncated}},
+		{"marctojson", []string{"-i", "-o", filepath.Join(dir, "out.json"), truncated}},
+		{"marctotsv", []string{"-i", "-o", filepath.Join(dir, "out.tsv"), truncated, "001"}},
+		{"marctosql", []string{"-i", "-o", filepath.Join(dir, "out.db"), truncated}},
+	}
+	for _, tt := range tests {
+		err := runCommand(t, tt.name, tt.args...)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%s %v => %v, want: %v", tt.name, tt.args, err, io.ErrUnexpectedEOF)
+		}
+	}
+	if err := runCommand(t, "marctojson", "-i", "-o", filepath.Join(dir, "out.json"), "../../fixtures/journals.mrc.gz"); err != nil {
+		t.Errorf("marctojson -i => %v, want: nil", err)
+	}
+}
+
+// recordIDs returns the sorted ids of the records in a file
+func recordIDs(t *testing.T, filename string) []string {
+	var ids []string
+	err := marctools.DefaultIdentifierSpec.WalkRecords(filename, false, func(id string, rr *marctools.RawRecord) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestSnapshotWeirdIDs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "snapshot.mrc")
+	// ids contain spaces, quotes and other punctuation
+	if err := runCommand(t, "marcsnapshot", "-l", "64", "-o", output, "../../fixtures/weird_ids.mrc"); err != nil {
+		t.Fatal(err)
+	}
+	got, want := recordIDs(t, output), recordIDs(t, "../../fixtures/weird_ids.mrc")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("marcsnapshot => %q, want: %q", got, want)
+	}
+}
+
+func TestDumpLegacySubFields(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "heb.txt")
+	if err := runCommand(t, "marcdump", "-o", output, "../../fixtures/heb.mrc"); err != nil {
+		t.Fatal(err)
+	}
+	// fixtures/heb.txt is the output of marcdump before records were parsed
+	// with marctools.ParseRecord
+	got, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("../../fixtures/heb.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("marcdump => %s, want: %s", got, want)
+	}
+}
+
+func TestBulkStopsOnFailedRequest(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := runCommand(t, "marctojson", "-bulk", "es", "-url", server.URL, "-chunk", "1", "-b", "1", "../../fixtures/journals.mrc")
+	if err == nil {
+		t.Fatal("marctojson => nil, want: error")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 1 {
+		t.Errorf("requests => %d, want: 1", requests)
+	}
+}
+
+func TestMapCompressedInput(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "map.tsv")
+	for _, filename := range []string{"../../fixtures/journals.mrc.gz", "../../fixtures/journals.mrc.bz2"} {
+		err := runCommand(t, "marcmap", "-o", output, filename)
+		if err == nil || !strings.Contains(err.Error(), "compressed input is not supported") {
+			t.Errorf("marcmap %s => %v, want: compressed input is not supported", filename, err)
+		}
+	}
+	if err := runCommand(t, "marcmap", "-o", output, "../../fixtures/journals.mrc"); err != nil {
+		t.Errorf("marcmap journals.mrc => %v, want: nil", err)
+	}
+}
+
+func TestToTSVArgs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	output := filepath.Join(dir, "out.tsv")
+	// a mistyped tag must not be taken for a missing file
+	err := runCommand(t, "marctotsv", "-o", output, "../../fixtures/journals.mrc", "24", "001")
+	if err == nil || !strings.Contains(err.Error(), "24: neither an input file nor a tag") {
+		t.Errorf("marctotsv journals.mrc 24 001 => %v, want: 24: neither an input file nor a tag", err)
+	}
+	// a file named like a tag is passed with a path
+	b, err := ioutil.ReadFile("../../fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "001")
+	if err := ioutil.WriteFile(filename, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCommand(t, "marctotsv", "-o", output, filename, "001"); err != nil {
+		t.Errorf("marctotsv %s 001 => %v, want: nil", filename, err)
+	}
+	if b, err = ioutil.ReadFile(output); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSpace(string(b)), "\n")
+	sort.Strings(got)
+	if want := recordIDs(t, filename); !reflect.DeepEqual(got, want) {
+		t.Errorf("marctotsv %s 001 => %v, want: %v", filename, got, want)
+	}
+}
+
+func TestReadErrorKeepsOutput(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	b, err := ioutil.ReadFile("../../fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := filepath.Join(dir, "truncated.mrc")
+	if err := ioutil.WriteFile(truncated, b[:len(b)*2/3], 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the records before the error are written completely
+	var complete int
+	_ = marctools.DefaultIdentifierSpec.WalkRecords(truncated, false, func(id string, rr *marctools.RawRecord) error {
+		complete++
+		return nil
+	})
+	if complete == 0 {
+		t.Fatal("no complete records in truncated file")
+	}
+	var tests = []struct {
+		name string
+		args []string
+	}{
+		{"marctojson", []string{"-w", "4", "-b", "1", "-o", filepath.Join(dir, "out.json"), truncated}},
+		{"marctotsv", []string{"-w", "4", "-b", "1", "-o", filepath.Join(dir, "out.tsv"), truncated, "001"}},
+	}
+	for _, tt := range tests {
+		if err := runCommand(t, tt.name, tt.args...); err == nil {
+			t.Errorf("%s %v => nil, want: error", tt.name, tt.args)
+		}
+		output, err := ioutil.ReadFile(tt.args[5])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(output), "\n"); n != complete {
+			t.Errorf("%s %v => %d lines, want: %d", tt.name, tt.args, n, complete)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+// recordReader is implemented by the readers of the text formats, e.g.
+// marctools.XMLReader
+type recordReader interface {
+	Next() (*marc22.Record, error)
+}
+
+// convertFiles writes the records of all input files as binary MARC; open
+// returns a reader for the text format of a single file, cf. inputFlags.open
+func convertFiles(env *Env, open func(r io.Reader, filename string) recordReader) error {
+	filenames, err := marctools.Inputs(env.Args)
+	if err != nil {
+		return err
+	}
+	writer, err := env.Writer()
+	if err != nil {
+		return err
+	}
+	w := marctools.NewWriter(writer)
+	for _, filename := range filenames {
+		if err := convertFile(env, filename, open, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertFile writes all records of a single file as binary MARC
+func convertFile(env *Env, filename string, open func(r io.Reader, filename string) recordReader, w *marctools.Writer) error {
+	file, err := marctools.OpenInput(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writeRecords(env, filename, open(file, filename), w)
+}
+
+// writeRecords writes all records of a reader as binary MARC. With -i,
+// invalid records and records, that cannot be represented in ISO 2709, are
+// skipped.
+func writeRecords(env *Env, filename string, reader recordReader, w *marctools.Writer) error {
+	for i := 0; ; i++ {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		var rerr *marctools.RecordError
+		if err != nil && !errors.As(err, &rerr) {
+			return err
+		}
+		if err == nil {
+			if err = w.Write(record); err != nil {
+				err = writeError(filename, i, record, err)
+			}
+		}
+		if err != nil {
+			if env.IgnoreErrors {
+				log.Println(err)
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// writeError adds the position and, if available, the identifier of the
+// record to an error
+func writeError(filename string, index int, record *marc22.Record, err error) error {
+	loc := fmt.Sprintf("record %d", index)
+	if filename != marctools.Stdin {
+		loc = filename + ": " + loc
+	}
+	if id, ierr := marctools.DefaultIdentifierSpec.Identifier(record); ierr == nil {
+		loc = fmt.Sprintf("%s (%s)", loc, id)
+	}
+	return fmt.Errorf("%s: %w", loc, err)
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "count",
+		Alias:       "marccount",
+		Args:        "[MARCFILE ...]",
+		Description: "count records in MARC files",
+		Uses:        UsesOutput,
+		Setup:       setupCount,
+	})
+}
+
+func setupCount(fs *flag.FlagSet) func(env *Env) error {
+	return func(env *Env) error {
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		for _, filename := range filenames {
+			file, err := marctools.OpenInput(filename)
+			if err != nil {
+				return err
+			}
+			count, err := marctools.CountRecords(file)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+			env.Logf("%s: %d", filename, count)
+			total += count
+		}
+
+		w, err := env.Writer()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, total)
+		return err
+	}
+}
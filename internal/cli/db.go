@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "db",
+		Alias:       "marcdb",
+		Args:        "[MARCFILE ...]",
+		Description: "store MARC records in an sqlite3 database",
+		Uses:        UsesOutput,
+		OutputUsage: "output sqlite3 filename",
+		Setup:       setupDB,
+	})
+}
+
+func setupDB(fs *flag.FlagSet) func(env *Env) error {
+	secondary := fs.String("secondary", "", "add a secondary value to the row")
+	encodeRecord := fs.Bool("encode", false, "base64 encode record before inserting it")
+	identifier := addIdentifierFlags(fs, true)
+
+	return func(env *Env) error {
+		if env.Output == "" {
+			return errors.New("output sqlite3 filename required (-o)")
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+		spec, err := identifier.parse()
+		if err != nil {
+			return err
+		}
+
+		// prepare sqlite3 output
+		db, err := sql.Open("sqlite3", env.Output)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		// prepare table
+		init := `CREATE TABLE IF NOT EXISTS store (id TEXT, secondary TEXT, record BLOB, PRIMARY KEY (id, secondary))`
+		if _, err = db.Exec(init); err != nil {
+			return fmt.Errorf("%w: %s", err, init)
+		}
+
+		// prepare statement
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.Prepare("INSERT INTO store VALUES (?, ?, ?)")
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		defer stmt.Close()
+
+		for _, filename := range filenames {
+			env.Logf("storing records from %s", filename)
+			err := spec.WalkRecords(filename, *identifier.safe, func(id string, rr *marctools.RawRecord) error {
+				var s string
+				if *encodeRecord {
+					s = base64.StdEncoding.EncodeToString(rr.Data)
+				} else {
+					s = string(rr.Data)
+				}
+				_, err := stmt.Exec(id, *secondary, s)
+				return err
+			})
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		// create index
+		if _, err = tx.Exec("CREATE INDEX IF NOT EXISTS idx_store_id ON store (id)"); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "dump",
+		Alias:       "marcdump",
+		Args:        "[MARCFILE ...]",
+		Description: "dump MARC records in a human readable form",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupDump,
+	})
+}
+
+func setupDump(fs *flag.FlagSet) func(env *Env) error {
+	charset := addCharsetFlags(fs)
+	format := fs.String("format", "text", "output format: text, mrk (MARCMaker, as used by MarcEdit) or aleph (Aleph sequential)")
+	filterVar := fs.String("r", "", "only dump the selected fields, e.g. 001,245.a,6XX,856[40],-9XX")
+
+	return func(env *Env) error {
+		if err := charset.init(); err != nil {
+			return err
+		}
+		if *format != "text" && *format != "mrk" && *format != "aleph" {
+			return fmt.Errorf("unknown format: %s", *format)
+		}
+		selector, err := marctools.ParseSelector(*filterVar)
+		if err != nil {
+			return err
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+		w, err := env.Writer()
+		if err != nil {
+			return err
+		}
+
+		aleph := marctools.NewAlephWriter(w)
+
+		reader := marctools.NewMultiReader(filenames)
+		reader.Resync = env.IgnoreErrors
+		defer reader.Close()
+
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			var serr *marctools.SkipError
+			if err != nil && !errors.As(err, &serr) {
+				return err
+			}
+			var record *marc22.Record
+			if err == nil {
+				record, err = rr.Record()
+			}
+			if err == nil {
+				err = marctools.ToUTF8(record, *charset.charset)
+			}
+			if err != nil {
+				if env.IgnoreErrors {
+					log.Println(err)
+					continue
+				}
+				return err
+			}
+			record = selector.Apply(record)
+			if *format == "aleph" {
+				if err := aleph.Write(record); err != nil {
+					return err
+				}
+				continue
+			}
+			if *format == "mrk" {
+				b, err := marctools.MarshalMRK(record)
+				if err == nil {
+					_, err = fmt.Fprintf(w, "%s\n", b)
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s\n", marctools.LegacySubFields(record).String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
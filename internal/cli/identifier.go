@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"flag"
+
+	"github.com/ubleipzig/marctools"
+)
+
+// identifierFlags are used by commands, that key records by identifier
+type identifierFlags struct {
+	spec *string
+	safe *bool
+}
+
+// addIdentifierFlags registers -id and optionally -safe
+func addIdentifierFlags(fs *flag.FlagSet, safe bool) *identifierFlags {
+	f := &identifierFlags{
+		spec: fs.String("id", "001", "record identifier, e.g. 001, 003+001;sep=: or 035.a=(DE-576);strip"),
+		safe: new(bool),
+	}
+	if safe {
+		f.safe = fs.Bool("safe", false, "use slower, but safer method to extract record identifiers")
+	}
+	return f
+}
+
+// parse returns the identifier specification
+func (f *identifierFlags) parse() (*marctools.IdentifierSpec, error) {
+	return marctools.ParseIdentifierSpec(*f.spec)
+}
@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+// Input formats, cf. addInputFlags
+const (
+	inputMARC  = "marc"
+	inputAleph = "aleph"
+)
+
+// inputFlags select the input format of commands, that read parsed records
+type inputFlags struct {
+	format *string
+}
+
+// addInputFlags registers -in
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	return &inputFlags{
+		format: fs.String("in", inputMARC, "input format: marc (ISO 2709) or aleph (Aleph sequential)"),
+	}
+}
+
+// open returns a reader for all records of the given files
+func (f *inputFlags) open(env *Env, filenames []string) (recordReadCloser, error) {
+	switch *f.format {
+	case inputMARC:
+		reader := marctools.NewMultiReader(filenames)
+		reader.Resync = env.IgnoreErrors
+		return &marcReader{MultiReader: reader}, nil
+	case inputAleph:
+		return &multiTextReader{filenames: filenames, open: func(r io.Reader, filename string) recordReader {
+			reader := marctools.NewAlephReader(r)
+			if filename != marctools.Stdin {
+				reader.Filename = filename
+			}
+			return reader
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown input format: %s", *f.format)
+	}
+}
+
+// recoverable reports whether reading can continue after an error, which is
+// the case for skipped data and records, that cannot be parsed, but not for
+// errors of the stream, cf. streamError
+func recoverable(err error) bool {
+	var serr *marctools.SkipError
+	if errors.As(err, &serr) {
+		return true
+	}
+	var sterr *streamError
+	if errors.As(err, &sterr) {
+		return false
+	}
+	var rerr *marctools.RecordError
+	return errors.As(err, &rerr)
+}
+
+// streamError marks errors of a marctools.Reader, which are final, e.g. a
+// truncated file; the reader returns the same error on every call
+type streamError struct {
+	err error
+}
+
+func (e *streamError) Error() string {
+	return e.err.Error()
+}
+
+func (e *streamError) Unwrap() error {
+	return e.err
+}
+
+type recordReadCloser interface {
+	recordReader
+	// Origin returns the origin of the last record, numbered seq
+	Origin(seq int64) *marctools.RecordOrigin
+	Close() error
+}
+
+// marcReader returns parsed records from a marctools.MultiReader
+type marcReader struct {
+	*marctools.MultiReader
+	last *marctools.RawRecord
+}
+
+func (r *marcReader) Next() (*marc22.Record, error) {
+	rr, err := r.MultiReader.Next()
+	if err != nil {
+		var serr *marctools.SkipError
+		if err == io.EOF || errors.As(err, &serr) {
+			return nil, err
+		}
+		return nil, &streamError{err}
+	}
+	r.last = rr
+	return rr.Record()
+}
+
+func (r *marcReader) Origin(seq int64) *marctools.RecordOrigin {
+	return marctools.NewRecordOrigin(r.last, seq)
+}
+
+// multiTextReader reads the records of multiple files with a reader for a
+// text format, e.g. marctools.AlephReader
+type multiTextReader struct {
+	filenames []string
+	open      func(r io.Reader, filename string) recordReader
+	filename  string // the file currently read
+	file      io.ReadCloser
+	reader    recordReader
+}
+
+func (r *multiTextReader) Next() (*marc22.Record, error) {
+	for {
+		if r.reader == nil {
+			if len(r.filenames) == 0 {
+				return nil, io.EOF
+			}
+			file, err := marctools.OpenInput(r.filenames[0])
+			if err != nil {
+				return nil, err
+			}
+			r.file, r.reader = file, r.open(file, r.filenames[0])
+			r.filename = r.filenames[0]
+			r.filenames = r.filenames[1:]
+		}
+		record, err := r.reader.Next()
+		if err != io.EOF {
+			return record, err
+		}
+		if err := r.Close(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *multiTextReader) Origin(seq int64) *marctools.RecordOrigin {
+	return &marctools.RecordOrigin{Filename: r.filename, Seq: seq, Offset: -1, Length: -1}
+}
+
+func (r *multiTextReader) Close() error {
+	r.reader = nil
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "jsontomarc",
+		Alias:       "jsontomarc",
+		Args:        "[JSONFILE ...]",
+		Description: "convert marctojson output back to binary MARC",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupJSONToMARC,
+	})
+}
+
+// lossyHintReader suggests -format ordered or -lossy for records, whose
+// order cannot be restored
+type lossyHintReader struct {
+	*marctools.JSONReader
+}
+
+func (r lossyHintReader) Next() (*marc22.Record, error) {
+	record, err := r.JSONReader.Next()
+	if errors.Is(err, marctools.ErrAmbiguousOrder) {
+		err = fmt.Errorf("%w (use marctojson -format ordered -l for a lossless round trip, or -lossy to accept the order of the JSON document)", err)
+	}
+	return record, err
+}
+
+func setupJSONToMARC(fs *flag.FlagSet) func(env *Env) error {
+	recordKey := fs.String("recordkey", "record", "key name of the record")
+	lossy := fs.Bool("lossy", false, "accept records, whose field and subfield order cannot be restored, e.g. marctojson output without -format ordered")
+
+	return func(env *Env) error {
+		return convertFiles(env, func(r io.Reader, filename string) recordReader {
+			reader := marctools.NewJSONReader(r)
+			reader.RecordKey = *recordKey
+			reader.Lossy = *lossy
+			if filename != marctools.Stdin {
+				reader.Filename = filename
+			}
+			return lossyHintReader{reader}
+		})
+	}
+}
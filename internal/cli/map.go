@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "map",
+		Alias:       "marcmap",
+		Args:        "[MARCFILE]",
+		Description: "create a seekmap of (id, offset, length) tuples",
+		Uses:        UsesOutput,
+		OutputUsage: "output to sqlite3 file",
+		Setup:       setupMap,
+	})
+}
+
+func setupMap(fs *flag.FlagSet) func(env *Env) error {
+	identifier := addIdentifierFlags(fs, true)
+
+	return func(env *Env) error {
+		// offsets are relative to a single file
+		if len(env.Args) > 1 {
+			return flag.ErrHelp
+		}
+		filename := marctools.Stdin
+		if len(env.Args) == 1 {
+			filename = env.Args[0]
+		}
+		// offsets into decompressed data cannot be used to seek in the file
+		if filename != marctools.Stdin {
+			c, err := marctools.DetectCompression(filename)
+			if err != nil {
+				return err
+			}
+			if c != marctools.CompressionNone {
+				return fmt.Errorf("%s: %s compressed input is not supported, please decompress first", filename, c)
+			}
+		}
+		spec, err := identifier.parse()
+		if err != nil {
+			return err
+		}
+		if env.Output != "" {
+			return spec.WriteMarcMapSqlite(filename, env.Output, *identifier.safe)
+		}
+		w, err := env.Writer()
+		if err != nil {
+			return err
+		}
+		return spec.WriteMarcMap(filename, w, *identifier.safe)
+	}
+}
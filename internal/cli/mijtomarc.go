@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"flag"
+	"io"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "mijtomarc",
+		Alias:       "mijtomarc",
+		Args:        "[JSONFILE ...]",
+		Description: "convert MARC-in-JSON to binary MARC",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupMIJToMARC,
+	})
+}
+
+func setupMIJToMARC(fs *flag.FlagSet) func(env *Env) error {
+	return func(env *Env) error {
+		return convertFiles(env, func(r io.Reader, filename string) recordReader {
+			reader := marctools.NewMIJReader(r)
+			if filename != marctools.Stdin {
+				reader.Filename = filename
+			}
+			return reader
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"flag"
+	"io"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "mrktomarc",
+		Alias:       "mrktomarc",
+		Args:        "[MRKFILE ...]",
+		Description: "convert MARCMaker (MRK) text to binary MARC",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupMRKToMARC,
+	})
+}
+
+func setupMRKToMARC(fs *flag.FlagSet) func(env *Env) error {
+	return func(env *Env) error {
+		return convertFiles(env, func(r io.Reader, filename string) recordReader {
+			reader := marctools.NewMRKReader(r)
+			if filename != marctools.Stdin {
+				reader.Filename = filename
+			}
+			return reader
+		})
+	}
+}
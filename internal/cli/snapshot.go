@@ -0,0 +1,308 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "snapshot",
+		Alias:       "marcsnapshot",
+		Args:        "MARCFILE [MARCFILE, ...]",
+		Description: "keep the newest records among multiple versions in a set of files",
+		Uses:        UsesOutput,
+		Setup:       setupSnapshot,
+	})
+}
+
+type seekInfo struct {
+	Offset int
+	Length int
+}
+
+func lineCounter(r io.Reader) (int, error) {
+	buf := make([]byte, 32784)
+	count := 0
+	lineSep := []byte{'\n'}
+
+	for {
+		c, err := r.Read(buf)
+		if err != nil && err != io.EOF {
+			return count, err
+		}
+
+		count += bytes.Count(buf[:c], lineSep)
+
+		if err == io.EOF {
+			break
+		}
+	}
+	return count, nil
+}
+
+// splitTabs splits a line of a map or tuple file into its fields
+func splitTabs(line string) []string {
+	return strings.Split(strings.TrimSuffix(line, "\n"), "\t")
+}
+
+// mapFile writes the seek map of a given file into a temporary file and the
+// (id, 005, filename) tuples to w. Returns the name of the map file.
+func mapFile(spec *marctools.IdentifierSpec, filename string, w io.Writer) (string, error) {
+	file, err := ioutil.TempFile("", "marcsnapshot-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	mw := bufio.NewWriter(file)
+	err = spec.WalkRecords(filename, false, func(id string, rr *marctools.RawRecord) error {
+		timestamp, _, err := rr.ControlField("005")
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(mw, "%s\t%d\t%d\n", id, rr.Offset, rr.Length); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\n", id, strings.TrimSpace(timestamp), filename)
+		return err
+	})
+	if err != nil {
+		return file.Name(), err
+	}
+	return file.Name(), mw.Flush()
+}
+
+func setupSnapshot(fs *flag.FlagSet) func(env *Env) error {
+	length := fs.Int("l", 9, "prefix length to use for comparison")
+	force := fs.Bool("f", false, "overwrite existing files")
+	identifier := addIdentifierFlags(fs, false)
+
+	return func(env *Env) error {
+		for _, name := range []string{"cut", "sort", "tac", "uniq"} {
+			_, err := exec.LookPath(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(env.Args) == 0 {
+			return flag.ErrHelp
+		}
+		filenames, err := marctools.ExpandPatterns(env.Args)
+		if err != nil {
+			return err
+		}
+
+		// records are copied from the original files by offset
+		for _, fn := range filenames {
+			if fn == marctools.Stdin {
+				return errors.New("standard input is not supported, since records are copied by offset")
+			}
+			c, err := marctools.DetectCompression(fn)
+			if err != nil {
+				return err
+			}
+			if c != marctools.CompressionNone {
+				return fmt.Errorf("%s: %s compressed input is not supported, please decompress first", fn, c)
+			}
+		}
+
+		spec, err := identifier.parse()
+		if err != nil {
+			return err
+		}
+
+		// a single output file for all (id, 005, filename) tuples
+		file, err := ioutil.TempFile("", "marcsnapshot-")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := os.Remove(file.Name())
+			if err != nil {
+				log.Println(err)
+			}
+		}()
+
+		mapfiles := make(map[string]string)
+		tw := bufio.NewWriter(file)
+
+		for _, fn := range filenames {
+			env.Logf("extracting record map and timestamps from %s", fn)
+			mapfile, err := mapFile(spec, fn, tw)
+			defer func() {
+				err := os.Remove(mapfile)
+				if err != nil {
+					log.Println(err)
+				}
+			}()
+			if err != nil {
+				return err
+			}
+			mapfiles[fn] = mapfile
+		}
+
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		err = file.Sync()
+		if err != nil {
+			return err
+		}
+
+		// total number of records
+		file.Seek(0, os.SEEK_SET)
+		total, err := lineCounter(file)
+		if err != nil {
+			return err
+		}
+
+		// write a sorted file, that cotains the (id, filename) of the latest records
+		sfile, err := ioutil.TempFile("", "marcsnapshot-")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err := os.Remove(sfile.Name())
+			if err != nil {
+				log.Println(err)
+			}
+		}()
+
+		env.Logf("sort and filter %s", sfile.Name())
+		// fields are separated by tabs, since ids may contain spaces
+		s := fmt.Sprintf(`LANG=C sort -t $'\t' -k1,2 %s | tac | uniq -w %d | cut -f 1,3`, file.Name(), *length)
+		cmd := exec.Command("bash", "-c", s)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return err
+		}
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+		if _, err := io.Copy(sfile, stdout); err != nil {
+			return err
+		}
+		if err := cmd.Wait(); err != nil {
+			return err
+		}
+
+		// filtered number of records
+		sfile.Seek(0, os.SEEK_SET)
+		filtered, err := lineCounter(sfile)
+		if err != nil {
+			return err
+		}
+		log.Printf("%d %d (%0.2f%%)\n", total, filtered, (100/float64(total))*float64(filtered))
+
+		env.Logf("gathering ids...")
+		// for each filename, keep a list of IDs to keep
+		idmap := make(map[string][]string)
+		for k := range mapfiles {
+			idmap[k] = make([]string, 0)
+		}
+
+		ff, err := os.Open(sfile.Name())
+		if err != nil {
+			return err
+		}
+		f := bufio.NewReader(ff)
+		for {
+			line, err := f.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if line == "" {
+				break
+			}
+			fields := splitTabs(line)
+			if len(fields) != 2 {
+				return fmt.Errorf("invalid map, expected (id, path), got: %s", line)
+			}
+			idmap[fields[1]] = append(idmap[fields[1]], fields[0])
+		}
+
+		env.Logf("building lookup table...")
+		// keep the seek information for all files in memory
+		table := make(map[string]map[string]seekInfo)
+
+		for k, v := range mapfiles {
+			ff, err := os.Open(v)
+			if err != nil {
+				return err
+			}
+			f := bufio.NewReader(ff)
+			for {
+				line, err := f.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return err
+				}
+				if line == "" {
+					break
+				}
+				fields := splitTabs(line)
+				if len(fields) != 3 {
+					return fmt.Errorf("invalid map, expected (id, offset, length), got: %s", line)
+				}
+				offset, err := strconv.Atoi(fields[1])
+				if err != nil {
+					return fmt.Errorf("invalid offset in %s", line)
+				}
+				length, err := strconv.Atoi(fields[2])
+				if err != nil {
+					return fmt.Errorf("invalid length in %s", line)
+				}
+				_, ok := table[k]
+				if !ok {
+					table[k] = make(map[string]seekInfo)
+				}
+				table[k][fields[0]] = seekInfo{Offset: offset, Length: length}
+			}
+			ff.Close()
+		}
+
+		// final filtered output
+		if env.Output != "" && !*force {
+			if _, err := os.Stat(env.Output); err == nil {
+				return errors.New("file exists, will not overwrite")
+			}
+		}
+		output, err := env.Writer()
+		if err != nil {
+			return err
+		}
+
+		for k, ids := range idmap {
+			env.Logf("extracting %d records from %s", len(ids), k)
+			ff, err := os.Open(k)
+			if err != nil {
+				return err
+			}
+			defer ff.Close()
+			for _, id := range ids {
+				seekinfo := table[k][id]
+				_, err := ff.Seek(int64(seekinfo.Offset), os.SEEK_SET)
+				if err != nil {
+					return err
+				}
+				_, err = io.CopyN(output, ff, int64(seekinfo.Length))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
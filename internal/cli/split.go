@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "split",
+		Alias:       "marcsplit",
+		Args:        "[MARCFILE ...]",
+		Description: "split MARC files into smaller pieces",
+		Setup:       setupSplit,
+	})
+}
+
+func setupSplit(fs *flag.FlagSet) func(env *Env) error {
+	directory := fs.String("d", ".", "directory to write to")
+	prefix := fs.String("s", "split-", "split file prefix")
+	size := fs.Int64("C", 1, "number of records per file")
+
+	return func(env *Env) error {
+		fi, err := os.Stat(*directory)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("arg to -d must be directory: %s", *directory)
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+		return marctools.SplitFiles(filenames, *size, *directory, *prefix)
+	}
+}
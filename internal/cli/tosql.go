@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"io"
+	"log"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "tosql",
+		Alias:       "marctosql",
+		Args:        "[MARCFILE ...]",
+		Description: "load MARC records into relational sqlite3 tables",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		OutputUsage: "output sqlite3 filename",
+		Setup:       setupToSQL,
+	})
+}
+
+func setupToSQL(fs *flag.FlagSet) func(env *Env) error {
+	identifier := addIdentifierFlags(fs, false)
+	charset := addCharsetFlags(fs)
+	input := addInputFlags(fs)
+
+	return func(env *Env) error {
+		if env.Output == "" {
+			return errors.New("output sqlite3 filename required (-o)")
+		}
+		if err := charset.init(); err != nil {
+			return err
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+		spec, err := identifier.parse()
+		if err != nil {
+			return err
+		}
+		reader, err := input.open(env, filenames)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		db, err := sql.Open("sqlite3", env.Output)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		w, err := marctools.NewSQLWriter(db)
+		if err != nil {
+			return err
+		}
+		w.Identifier = spec
+
+		var n int64
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if env.IgnoreErrors && recoverable(err) {
+					log.Printf("[EE] %s\n", err)
+					continue
+				}
+				w.Rollback()
+				return err
+			}
+			if err := marctools.ToUTF8(record, *charset.charset); err != nil {
+				if env.IgnoreErrors {
+					log.Printf("[EE] %s\n", err)
+					continue
+				}
+				w.Rollback()
+				return err
+			}
+			if err := w.Write(record); err != nil {
+				w.Rollback()
+				return err
+			}
+			n++
+		}
+		env.Logf("%d records loaded into %s", n, env.Output)
+		return w.Close()
+	}
+}
@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "totsv",
+		Alias:       "marctotsv",
+		Args:        "[MARCFILE ...] TAG [TAG, TAG, ...] (use ./001 for a file named like a tag)",
+		Description: "convert selected MARC tags to tab-separated or comma-separated values",
+		Uses:        UsesWorkers | UsesOutput | UsesIgnoreErrors,
+		Setup:       setupToTSV,
+	})
+}
+
+// tsvOptions control the conversion of records to rows
+type tsvOptions struct {
+	Tags                []string              // tags to dump
+	Selectors           []*marctools.Selector // parsed selector tags, cf. marctools.ColumnSelectors
+	FillNA              string                // placeholder if value is not available
+	Separator           string
+	SkipIncompleteLines bool   // skip lines, that do
+	Charset             string // source charset
+	IgnoreErrors        bool
+	Format              string // tsv or csv
+	Escape              bool   // escape TSV values, cf. marctools.EscapeTSV
+}
+
+// tsvWorker converts numbered batches of records to rows and sends the
+// formatted rows of each batch on the out channel
+func tsvWorker(in chan marctools.Batch, out chan marctools.BatchResult, wg *sync.WaitGroup, options tsvOptions) {
+	defer wg.Done()
+	for batch := range in {
+		var rows [][]string
+		for _, record := range batch.Records {
+			if err := marctools.ToUTF8(record, options.Charset); err != nil {
+				if !options.IgnoreErrors {
+					log.Fatalln(err)
+				}
+				log.Printf("[EE] %s\n", err)
+				continue
+			}
+			cols, err := marctools.RecordColumns(record, options.Tags, options.Selectors, options.FillNA, options.Separator, options.SkipIncompleteLines)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if len(cols) > 0 {
+				rows = append(rows, cols)
+			}
+		}
+		b, err := formatRows(rows, options.Format, options.Escape)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		out <- marctools.BatchResult{Seq: batch.Seq, Items: [][]byte{b}}
+	}
+}
+
+// formatRows serializes rows as TSV or CSV
+func formatRows(rows [][]string, format string, escape bool) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "tsv":
+		for _, row := range rows {
+			for i, v := range row {
+				if i > 0 {
+					buf.WriteString("\t")
+				}
+				if escape {
+					v = marctools.EscapeTSV(v)
+				}
+				buf.WriteString(v)
+			}
+			buf.WriteString("\n")
+		}
+		return buf.Bytes(), nil
+	case "csv":
+		// RFC 4180 requires CRLF line breaks
+		w := csv.NewWriter(&buf)
+		w.UseCRLF = true
+		err := w.WriteAll(rows)
+		return buf.Bytes(), err
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// tsvWriter writes the channel content to the writer as is and sends the
+// first error, if any, on done
+func tsvWriter(w io.Writer, in chan []byte, done chan error) {
+	var err error
+	for b := range in {
+		if err == nil {
+			_, err = w.Write(b)
+		}
+	}
+	done <- err
+}
+
+var tagPattern = regexp.MustCompile(`^([\dX?]{3}(\[..\])?(\.[a-z0-9]+)?|@.*)$`)
+
+// splitTagArgs separates the leading input files (or - for stdin) from the
+// tags, which start with the first argument, that looks like a tag; use
+// ./001 for a file named 001. Anything before the tags, that is not an
+// input file, is likely a mistyped tag and an error.
+func splitTagArgs(args []string) (files, tags []string, err error) {
+	for i, arg := range args {
+		if tagPattern.MatchString(arg) {
+			return args[:i], args[i:], nil
+		}
+		if arg == marctools.Stdin {
+			continue
+		}
+		if matches, _ := filepath.Glob(arg); len(matches) == 0 {
+			return nil, nil, fmt.Errorf("%s: neither an input file nor a tag", arg)
+		}
+	}
+	return args, nil, nil
+}
+
+func setupToTSV(fs *flag.FlagSet) func(env *Env) error {
+	fillna := fs.String("f", "<NULL>", "fill missing values with this")
+	separator := fs.String("s", "", "separator to use for multiple values")
+	skipIncompleteLines := fs.Bool("k", false, "skip incomplete lines (missing values)")
+	format := fs.String("format", "tsv", "output format: tsv or csv (RFC 4180)")
+	header := fs.Bool("header", false, "write a header row with the tags as column names")
+	columns := fs.String("columns", "", "comma separated column names for the header row, implies -header")
+	batchSize := fs.Int("b", 10000, "batch size for intercom")
+	escape := fs.Bool("escape", false, "escape backslashes, tabs and line breaks in values as \\\\, \\t, \\n and \\r (tsv only)")
+	charset := addCharsetFlags(fs)
+	input := addInputFlags(fs)
+
+	return func(env *Env) error {
+		args, tags, err := splitTagArgs(env.Args)
+		if err != nil {
+			return err
+		}
+		if len(tags) == 0 {
+			return errors.New("at least one tag is required")
+		}
+		if err := charset.init(); err != nil {
+			return err
+		}
+		filenames, err := marctools.Inputs(args)
+		if err != nil {
+			return err
+		}
+		selectors, err := marctools.ColumnSelectors(tags)
+		if err != nil {
+			return err
+		}
+		if *format != "tsv" && *format != "csv" {
+			return fmt.Errorf("unknown format: %s", *format)
+		}
+		if *escape && *format != "tsv" {
+			return errors.New("-escape requires -format tsv")
+		}
+		var names []string
+		switch {
+		case *columns != "":
+			names = strings.Split(*columns, ",")
+			if n := len(marctools.ColumnNames(tags)); len(names) != n {
+				return fmt.Errorf("got %d column names for %d columns", len(names), n)
+			}
+		case *header:
+			names = marctools.ColumnNames(tags)
+		}
+		w, err := env.Writer()
+		if err != nil {
+			return err
+		}
+		if names != nil {
+			b, err := formatRows([][]string{names}, *format, *escape)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		}
+
+		reader, err := input.open(env, filenames)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+
+		seq := marctools.NewSequencer(2 * env.Workers)
+		queue := make(chan marctools.Batch)
+		results := make(chan marctools.BatchResult)
+		rows := make(chan []byte)
+		done := make(chan error)
+
+		go seq.Reorder(results, rows)
+		go tsvWriter(w, rows, done)
+
+		options := tsvOptions{
+			Tags:                tags,
+			Selectors:           selectors,
+			FillNA:              *fillna,
+			Separator:           *separator,
+			SkipIncompleteLines: *skipIncompleteLines,
+			Charset:             *charset.charset,
+			IgnoreErrors:        env.IgnoreErrors,
+			Format:              *format,
+			Escape:              *escape,
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < env.Workers; i++ {
+			wg.Add(1)
+			go tsvWorker(queue, results, &wg, options)
+		}
+
+		var records []*marc22.Record
+		var readErr error
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				if env.IgnoreErrors && recoverable(err) {
+					log.Printf("[EE] %s\n", err)
+					continue
+				}
+				// the pipeline is drained below, before the output is closed
+				readErr = err
+				break
+			}
+
+			records = append(records, record)
+			if len(records) == *batchSize {
+				queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+				records = nil
+			}
+		}
+		queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+
+		close(queue)
+		wg.Wait()
+		close(results)
+		if err := <-done; readErr == nil {
+			readErr = err
+		}
+		return readErr
+	}
+}
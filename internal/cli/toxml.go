@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "toxml",
+		Alias:       "marctoxml",
+		Args:        "[MARCFILE ...]",
+		Description: "convert MARC to MARCXML, Dublin Core or MODS",
+		Uses:        UsesWorkers | UsesOutput | UsesIgnoreErrors,
+		Setup:       setupToXML,
+	})
+}
+
+func setupToXML(fs *flag.FlagSet) func(env *Env) error {
+	format := fs.String("format", marctools.FormatMARCXML, "output format: marcxml, dc (oai_dc) or mods")
+	filterVar := fs.String("r", "", "only dump the selected fields (marcxml only), e.g. 001,245.a,6XX,856[40],-9XX")
+	indent := fs.Bool("indent", false, "indent elements")
+	batchSize := fs.Int("b", 10000, "batch size for intercom")
+	charset := addCharsetFlags(fs)
+
+	return func(env *Env) error {
+		start, end, err := marctools.XMLCollection(*format)
+		if err != nil {
+			return err
+		}
+		if *filterVar != "" && *format != marctools.FormatMARCXML {
+			return errors.New("-r requires -format marcxml")
+		}
+		selector, err := marctools.ParseSelector(*filterVar)
+		if err != nil {
+			return err
+		}
+		if err := charset.init(); err != nil {
+			return err
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+
+		writer, err := env.Writer()
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(writer, start); err != nil {
+			return err
+		}
+
+		seq := marctools.NewSequencer(2 * env.Workers)
+		queue := make(chan marctools.Batch)
+		results := make(chan marctools.BatchResult)
+		lines := make(chan []byte)
+		done := make(chan error)
+		errc := make(chan error)
+
+		go func() {
+			for err := range errc {
+				log.Fatal(err)
+			}
+		}()
+
+		go seq.Reorder(results, lines)
+		go marctools.ChunkFanInWriter(singleChunkWriter("", writer), lines, done, nil)
+
+		var wg sync.WaitGroup
+		options := marctools.XMLConversionOptions{
+			Format:       *format,
+			Selector:     selector,
+			Indent:       *indent,
+			IgnoreErrors: env.IgnoreErrors,
+			Charset:      *charset.charset,
+			Errors:       errc,
+		}
+		for i := 0; i < env.Workers; i++ {
+			wg.Add(1)
+			go marctools.OrderedXMLBatchWorker(queue, results, &wg, options)
+		}
+
+		var records []*marc22.Record
+
+		reader := marctools.NewMultiReader(filenames)
+		reader.Resync = env.IgnoreErrors
+		defer reader.Close()
+
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			var serr *marctools.SkipError
+			if err != nil && !errors.As(err, &serr) {
+				return err
+			}
+			var record *marc22.Record
+			if err == nil {
+				record, err = rr.Record()
+			}
+			if err != nil {
+				if env.IgnoreErrors {
+					log.Println(err)
+					continue
+				}
+				return err
+			}
+			records = append(records, record)
+			if len(records) == *batchSize {
+				queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+				records = nil
+			}
+		}
+		queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+		close(queue)
+		wg.Wait()
+		close(results)
+		if err := <-done; err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(writer, end)
+		return err
+	}
+}
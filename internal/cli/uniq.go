@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "uniq",
+		Alias:       "marcuniq",
+		Args:        "[MARCFILE ...]",
+		Description: "keep only the first record for each identifier",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupUniq,
+	})
+}
+
+// loadExcludes reads a comma separated list of ids or a file with one id
+// per line into the set
+func loadExcludes(s string, spec *marctools.IdentifierSpec, set *marctools.StringSet) error {
+	if _, err := os.Stat(s); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "excluded ids interpreted as string\n")
+		for _, value := range strings.Split(s, ",") {
+			set.Add(spec.Normalize(value))
+		}
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "excluded ids interpreted as file\n")
+
+	// read one id per line from file
+	file, err := os.Open(s)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		set.Add(spec.Normalize(scanner.Text()))
+	}
+	return scanner.Err()
+}
+
+func setupUniq(fs *flag.FlagSet) func(env *Env) error {
+	exclude := fs.String("x", "", "comma separated list of ids to exclude (or filename with one id per line)")
+	identifier := addIdentifierFlags(fs, false)
+
+	return func(env *Env) error {
+		spec, err := identifier.parse()
+		if err != nil {
+			return err
+		}
+		// input files
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+		output, err := env.Writer()
+		if err != nil {
+			return err
+		}
+
+		// exclude list
+		excludedIds := marctools.NewStringSet()
+
+		if *exclude != "" {
+			if err := loadExcludes(*exclude, spec, excludedIds); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%d ids to exclude loaded\n", excludedIds.Size())
+		}
+
+		// collect the excluded ids here
+		excluded := make([]string, 0, 0)
+
+		// keep track of all ids
+		ids := marctools.NewStringSet()
+		// collect the duplicate ids; array, since same id may occur many times
+		// skipped could be an integer for now, because we do not display the skipped
+		// records (TODO: add flag to display skipped records)
+		skipped := make([]string, 0, 0)
+		// just count the total records and those without id
+		var counter, withoutID int
+
+		reader := marctools.NewMultiReader(filenames)
+		reader.Resync = env.IgnoreErrors
+		defer reader.Close()
+
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			var serr *marctools.SkipError
+			if err != nil && !errors.As(err, &serr) {
+				return err
+			}
+			var record *marc22.Record
+			if err == nil {
+				record, err = rr.Record()
+			}
+			if err != nil {
+				if env.IgnoreErrors {
+					fmt.Fprintf(os.Stderr, "skipping error: %s\n", err)
+					continue
+				}
+				return err
+			}
+
+			id, err := spec.Identifier(record)
+			if err == nil {
+				if ids.Contains(id) {
+					skipped = append(skipped, id)
+				} else if excludedIds.Contains(id) {
+					excluded = append(excluded, id)
+				} else {
+					ids.Add(id)
+					if _, err := output.Write(rr.Data); err != nil {
+						return err
+					}
+				}
+			} else if errors.Is(err, marctools.ErrMissingIdentifier) {
+				withoutID++
+			} else {
+				return err
+			}
+			counter++
+		}
+
+		fmt.Fprintf(os.Stderr, "%d records read\n", counter)
+		fmt.Fprintf(os.Stderr, "%d records written, %d skipped, %d excluded, %d without ID (%s)\n",
+			ids.Size(), len(skipped), len(excluded), withoutID, spec)
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/miku/marc22"
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "xmltojson",
+		Alias:       "marcxmltojson",
+		Args:        "[MARCFILE ...]",
+		Description: "convert MARCXML to JSON",
+		Uses:        UsesWorkers | UsesOutput | UsesIgnoreErrors,
+		Setup:       setupXMLToJSON,
+	})
+}
+
+// decodeXMLFile passes all records found in a MARCXML file to emit
+func decodeXMLFile(env *Env, filename string, emit func(*marc22.Record)) error {
+	file, err := marctools.OpenInput(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := marctools.NewXMLReader(file)
+	if filename != marctools.Stdin {
+		reader.Filename = filename
+	}
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var rerr *marctools.RecordError
+			if env.IgnoreErrors && errors.As(err, &rerr) {
+				log.Println(err)
+				continue
+			}
+			return err
+		}
+		emit(record)
+	}
+}
+
+func setupXMLToJSON(fs *flag.FlagSet) func(env *Env) error {
+	filterVar := fs.String("r", "", "only dump the selected fields, e.g. 001,245.a,6XX,856[40],-9XX")
+	includeLeader := fs.Bool("l", false, "dump the leader as well")
+	metaVar := fs.String("m", "", "a key=value pair to pass to meta")
+	plainMode := fs.Bool("p", false, "plain mode: dump without content and meta")
+	recordKey := fs.String("recordkey", "record", "key name of the record")
+	batchSize := fs.Int("b", 10000, "batch size for intercom")
+	format := addJSONFormatFlag(fs)
+
+	return func(env *Env) error {
+		if err := checkJSONFormat(*format); err != nil {
+			return err
+		}
+		filenames, err := marctools.Inputs(env.Args)
+		if err != nil {
+			return err
+		}
+
+		selector, err := marctools.ParseSelector(*filterVar)
+		if err != nil {
+			return err
+		}
+		metaMap, err := marctools.KeyValueStringToMap(*metaVar)
+		if err != nil {
+			return err
+		}
+
+		writer, err := env.Writer()
+		if err != nil {
+			return err
+		}
+
+		seq := marctools.NewSequencer(2 * env.Workers)
+		queue := make(chan marctools.Batch)
+		results := make(chan marctools.BatchResult)
+		lines := make(chan []byte)
+		done := make(chan error)
+		errc := make(chan error)
+
+		go func() {
+			for err := range errc {
+				log.Fatal(err)
+			}
+		}()
+
+		go seq.Reorder(results, lines)
+		go marctools.ChunkFanInWriter(singleChunkWriter("", writer), lines, done, nil)
+
+		options := marctools.JSONConversionOptions{
+			Format:        *format,
+			Selector:      selector,
+			MetaMap:       metaMap,
+			IncludeLeader: *includeLeader,
+			PlainMode:     *plainMode,
+			IgnoreErrors:  env.IgnoreErrors,
+			RecordKey:     *recordKey,
+			Errors:        errc,
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < env.Workers; i++ {
+			wg.Add(1)
+			go marctools.OrderedBatchWorker(queue, results, &wg, options)
+		}
+
+		var records []*marc22.Record
+		emit := func(record *marc22.Record) {
+			records = append(records, record)
+			if len(records) == *batchSize {
+				queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+				records = nil
+			}
+		}
+
+		// on errors, write the records decoded so far
+		for _, filename := range filenames {
+			if err = decodeXMLFile(env, filename, emit); err != nil {
+				break
+			}
+		}
+		queue <- marctools.Batch{Seq: seq.Next(), Records: records}
+
+		close(queue)
+		wg.Wait()
+		close(results)
+		if werr := <-done; err == nil {
+			err = werr
+		}
+		return err
+	}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"flag"
+	"io"
+
+	"github.com/ubleipzig/marctools"
+)
+
+func init() {
+	register(&Command{
+		Name:        "xmltomarc",
+		Alias:       "marcxmltomarc",
+		Args:        "[MARCXMLFILE ...]",
+		Description: "convert MARCXML to binary MARC",
+		Uses:        UsesOutput | UsesIgnoreErrors,
+		Setup:       setupXMLToMARC,
+	})
+}
+
+func setupXMLToMARC(fs *flag.FlagSet) func(env *Env) error {
+	return func(env *Env) error {
+		return convertFiles(env, func(r io.Reader, filename string) recordReader {
+			reader := marctools.NewXMLReader(r)
+			if filename != marctools.Stdin {
+				reader.Filename = filename
+			}
+			return reader
+		})
+	}
+}
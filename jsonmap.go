@@ -0,0 +1,269 @@
+package marctools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// jsonMember is a key and value of a JSON object, in document order
+type jsonMember struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// decodeObject returns the members of a JSON object in document order
+func decodeObject(b []byte) ([]jsonMember, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if t != json.Delim('{') {
+		return nil, fmt.Errorf("expected object, got %v", t)
+	}
+	var members []jsonMember
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var m jsonMember
+		m.Key = t.(string)
+		if err := dec.Decode(&m.Value); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// jsonLeader is the leader as written by RecordMap
+type jsonLeader struct {
+	Status  string `json:"status"`
+	CS      string `json:"cs"`
+	Type    string `json:"type"`
+	ImplDef string `json:"impldef"`
+	Raw     string `json:"raw"`
+}
+
+// defaultLeader is used, if a leader has no raw value
+const defaultLeader = "00000     2200000   4500"
+
+// leader returns the raw leader, with status, type, character encoding and
+// implementation defined values taking precedence, so these can be edited
+func (l *jsonLeader) leader() (string, error) {
+	if l.Raw == "" && l.Status == "" && l.Type == "" {
+		return "", fmt.Errorf("%w: record has no leader", ErrInvalidLeader)
+	}
+	raw := l.Raw
+	if raw == "" {
+		raw = defaultLeader
+	}
+	if len(raw) != 24 {
+		return "", fmt.Errorf("%w: expected 24 bytes, got %d", ErrInvalidLeader, len(raw))
+	}
+	b := []byte(raw)
+	for _, v := range []struct {
+		dst   []byte
+		value string
+		name  string
+	}{
+		{b[5:6], l.Status, "status"},
+		{b[6:7], l.Type, "type"},
+		{b[9:10], l.CS, "cs"},
+	} {
+		switch len(v.value) {
+		case 0:
+		case 1:
+			copy(v.dst, v.value)
+		default:
+			return "", fmt.Errorf("%w: invalid %s: %q", ErrInvalidLeader, v.name, v.value)
+		}
+	}
+	switch len(l.ImplDef) {
+	case 0:
+	case 5:
+		copy(b[7:9], l.ImplDef[0:2])
+		copy(b[17:20], l.ImplDef[2:5])
+	default:
+		return "", fmt.Errorf("%w: invalid impldef: %q", ErrInvalidLeader, l.ImplDef)
+	}
+	return string(b), nil
+}
+
+// UnmarshalRecordMap parses a single record as written by marctojson, cf.
+// RecordMap, which must include the leader (marctojson -l). If the object
+// has a member named recordKey, the record is taken from there, otherwise
+// the object itself is the record (marctojson -p).
+//
+// RecordMap keeps neither the order of fields nor of subfields: fields are
+// grouped by tag and, once marshalled, sorted by tag, subfields are grouped
+// by code and sorted by code, data before the first delimiter gets a code,
+// cf. LegacySubFields, and repeated control fields are lost. So records are
+// rejected with ErrAmbiguousOrder, if a tag has more than one field, a field
+// has more than one subfield code or a code, that is not a letter or digit,
+// unless lossy is true, in which case fields are written in the order of the
+// document and subfields grouped by code. Records written with
+// OrderedRecordMap (marctojson -format ordered) are restored exactly.
+func UnmarshalRecordMap(b []byte, recordKey string, lossy bool) (*marc22.Record, error) {
+	members, err := decodeObject(b)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRecord, err)
+	}
+	for _, m := range members {
+		if m.Key == recordKey && recordKey != "" {
+			if members, err = decodeObject(m.Value); err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, recordKey, err)
+			}
+			break
+		}
+	}
+
+	record := &marc22.Record{}
+	for _, m := range members {
+		switch {
+		case m.Key == "leader":
+			var l jsonLeader
+			if err := json.Unmarshal(m.Value, &l); err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidLeader, err)
+			}
+			if record.Leader, err = l.leader(); err != nil {
+				return nil, err
+			}
+			if record.LeaderParsed, err = parseLeaderTemplate(record.Leader); err != nil {
+				return nil, err
+			}
+		case m.Key == "fields":
+			var fields []map[string]json.RawMessage
+			if err := json.Unmarshal(m.Value, &fields); err != nil {
+				return nil, fmt.Errorf("%w: fields: %s", ErrInvalidRecord, err)
+			}
+			if err := addMIJFields(record, fields); err != nil {
+				return nil, err
+			}
+		case len(m.Key) != 3:
+			return nil, fmt.Errorf("%w: unexpected key %q (check -recordkey)", ErrInvalidRecord, m.Key)
+		case len(m.Value) > 0 && m.Value[0] == '"':
+			var data string
+			if err := json.Unmarshal(m.Value, &data); err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, m.Key, err)
+			}
+			record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: m.Key, Data: data})
+		default:
+			var fields []json.RawMessage
+			if err := json.Unmarshal(m.Value, &fields); err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, m.Key, err)
+			}
+			if len(fields) > 1 && !lossy {
+				return nil, fmt.Errorf("%w: %s: %d fields", ErrAmbiguousOrder, m.Key, len(fields))
+			}
+			for _, f := range fields {
+				field, err := unmarshalDataField(m.Key, f, lossy)
+				if err != nil {
+					return nil, err
+				}
+				record.DataFields = append(record.DataFields, field)
+			}
+		}
+	}
+	if record.LeaderParsed == nil {
+		return nil, fmt.Errorf("%w: record has no leader (use marctojson -l)", ErrInvalidLeader)
+	}
+	return record, nil
+}
+
+// unmarshalDataField parses a single data field of a record map
+func unmarshalDataField(tag string, b []byte, lossy bool) (marc22.DataField, error) {
+	field := marc22.DataField{Tag: tag}
+	members, err := decodeObject(b)
+	if err != nil {
+		return field, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, tag, err)
+	}
+	var codes int
+	var legacy bool
+	for _, m := range members {
+		switch m.Key {
+		case "ind1":
+			err = json.Unmarshal(m.Value, &field.Ind1)
+		case "ind2":
+			err = json.Unmarshal(m.Value, &field.Ind2)
+		default:
+			var values []string
+			err = json.Unmarshal(m.Value, &values)
+			for _, value := range values {
+				field.SubFields = append(field.SubFields, &marc22.SubField{Code: m.Key, Value: value})
+			}
+			codes++
+			legacy = legacy || !isSubfieldCode(m.Key)
+		}
+		if err != nil {
+			return field, fmt.Errorf("%w: %s: %s: %s", ErrInvalidRecord, tag, m.Key, err)
+		}
+	}
+	if (codes > 1 || legacy) && !lossy {
+		var subfields []string
+		for _, sf := range field.SubFields {
+			subfields = append(subfields, sf.Code)
+		}
+		return field, fmt.Errorf("%w: %s: subfields %s", ErrAmbiguousOrder, tag, strings.Join(subfields, ","))
+	}
+	return field, nil
+}
+
+// isSubfieldCode returns true for a lowercase letter or a digit
+func isSubfieldCode(code string) bool {
+	return len(code) == 1 && (code[0] >= 'a' && code[0] <= 'z' || code[0] >= '0' && code[0] <= '9')
+}
+
+// JSONReader reads records as written by marctojson, one JSON object per
+// record, cf. UnmarshalRecordMap.
+type JSONReader struct {
+	// Filename is used in errors, if not empty.
+	Filename string
+	// RecordKey is the key of the record, cf. marctojson -recordkey.
+	RecordKey string
+	// Lossy allows records, whose field or subfield order cannot be restored.
+	Lossy bool
+
+	dec   *json.Decoder
+	index int64
+	err   error
+}
+
+// NewJSONReader returns a reader, that reads from r.
+func NewJSONReader(r io.Reader) *JSONReader {
+	return &JSONReader{RecordKey: "record", dec: json.NewDecoder(r)}
+}
+
+// Next returns the next record or io.EOF. Invalid records are reported as
+// *RecordError and reading can continue. Other errors, e.g. JSON syntax
+// errors, are final, later calls return the same error.
+func (r *JSONReader) Next() (*marc22.Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var raw json.RawMessage
+	if err := r.dec.Decode(&raw); err != nil {
+		switch {
+		case err == io.EOF:
+			r.err = err
+		case r.Filename != "":
+			r.err = fmt.Errorf("%s: record %d: %w: %s", r.Filename, r.index, ErrInvalidRecord, err)
+		default:
+			r.err = fmt.Errorf("record %d: %w: %s", r.index, ErrInvalidRecord, err)
+		}
+		return nil, r.err
+	}
+	index := r.index
+	r.index++
+	record, err := UnmarshalRecordMap(raw, r.RecordKey, r.Lossy)
+	if err != nil {
+		return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Err: err}
+	}
+	return record, nil
+}
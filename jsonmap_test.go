@@ -0,0 +1,168 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalRecordMapRoundTrip(t *testing.T) {
+	for _, filename := range []string{"./fixtures/2_fields.mrc", "./fixtures/2_repeated_fields.mrc"} {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			for _, plain := range []bool{false, true} {
+				options := JSONConversionOptions{IncludeLeader: true, PlainMode: plain, RecordKey: "content"}
+				b, err := MarshalRecord(record, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// unless lossy, records are either restored exactly or rejected
+				for _, lossy := range []bool{false, true} {
+					parsed, err := UnmarshalRecordMap(b, "content", lossy)
+					if !lossy && errors.Is(err, ErrAmbiguousOrder) {
+						continue
+					}
+					if err != nil {
+						t.Fatalf("UnmarshalRecordMap(%s) => %s", b, err)
+					}
+					data, err := Marshal(parsed)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !bytes.Equal(data, rr.Data) {
+						t.Errorf("UnmarshalRecordMap(%s, %v) => %q, want: %q", b, lossy, data, rr.Data)
+					}
+				}
+			}
+		}
+		file.Close()
+	}
+}
+
+func TestUnmarshalOrderedRecordMapRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			options := JSONConversionOptions{Format: FormatOrdered, IncludeLeader: true, RecordKey: "record"}
+			b, err := MarshalRecord(record, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := UnmarshalRecordMap(b, "record", false)
+			if err != nil {
+				t.Fatalf("UnmarshalRecordMap(%s) => %s", b, err)
+			}
+			data, err := Marshal(parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, rr.Data) {
+				t.Errorf("%s: UnmarshalRecordMap(%s) => %q, want: %q", filename, b, data, rr.Data)
+			}
+		}
+		file.Close()
+	}
+}
+
+func TestUnmarshalRecordMap(t *testing.T) {
+	var tests = []struct {
+		in     string
+		lossy  bool
+		leader string
+		tags   []string
+		err    error
+	}{
+		{`{"record": {"leader": {"raw": "00000nam a2200000 a 4500"}, "001": "1",
+			"245": [{"ind1": "1", "ind2": "0", "a": ["Title"], "c": ["Author"]}]}, "meta": {}}`,
+			false, "", nil, ErrAmbiguousOrder},
+		{`{"record": {"leader": {"raw": "00000nam a2200000 a 4500"}, "001": "1",
+			"245": [{"ind1": "1", "ind2": "0", "a": ["Title"], "c": ["Author"]}]}, "meta": {}}`,
+			true, "00000nam a2200000 a 4500", []string{"001", "245"}, nil},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500"},
+			"650": [{"ind1": " ", "ind2": "0", "a": ["Topic"]}, {"ind1": " ", "ind2": "7", "a": ["Thema"]}]}`,
+			false, "", nil, ErrAmbiguousOrder},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500"},
+			"260": [{"ind1": " ", "ind2": " ", " ": [""]}]}`,
+			false, "", nil, ErrAmbiguousOrder},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500", "status": "d", "cs": " "}, "001": "1"}`,
+			false, "00000dam  2200000 a 4500", []string{"001"}, nil},
+		{`{"leader": {"status": "c", "type": "a", "cs": "a", "impldef": "m    "}, "001": "1"}`,
+			false, "00000cam a2200000   4500", []string{"001"}, nil},
+		{`{"record": {"001": "1"}, "meta": {}}`, false, "", nil, ErrInvalidLeader},
+		{`{"leader": {"raw": "short"}}`, false, "", nil, ErrInvalidLeader},
+		{`{"record": {"leader": {"raw": "00000nam a2200000 a 4500"}}, "meta": {}}`,
+			false, "00000nam a2200000 a 4500", nil, nil},
+		{`{"data": {"leader": {"raw": "00000nam a2200000 a 4500"}}, "meta": {}}`, false, "", nil, ErrInvalidRecord},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500"},
+			"650": [{"ind1": " ", "ind2": "0", "a": ["Topic"], "x": ["History", "Sources"]}]}`,
+			false, "", nil, ErrAmbiguousOrder},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500"},
+			"650": [{"ind1": " ", "ind2": "0", "a": ["Topic"], "x": ["History", "Sources"]}]}`,
+			true, "00000nam a2200000 a 4500", []string{"650"}, nil},
+		{`{"leader": {"raw": "00000nam a2200000 a 4500"},
+			"650": [{"ind1": " ", "ind2": "0", "x": ["History", "Sources"]}]}`,
+			false, "00000nam a2200000 a 4500", []string{"650"}, nil},
+		{`[]`, false, "", nil, ErrInvalidRecord},
+	}
+
+	for _, tt := range tests {
+		record, err := UnmarshalRecordMap([]byte(tt.in), "record", tt.lossy)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("UnmarshalRecordMap(%s) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		if record.Leader != tt.leader {
+			t.Errorf("UnmarshalRecordMap(%s) => leader %q, want: %q", tt.in, record.Leader, tt.leader)
+		}
+		var tags []string
+		for _, f := range record.ControlFields {
+			tags = append(tags, f.Tag)
+		}
+		for _, f := range record.DataFields {
+			tags = append(tags, f.Tag)
+		}
+		if !reflect.DeepEqual(tags, tt.tags) {
+			t.Errorf("UnmarshalRecordMap(%s) => tags %v, want: %v", tt.in, tags, tt.tags)
+		}
+	}
+}
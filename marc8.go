@@ -0,0 +1,661 @@
+package marctools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/miku/marc22"
+)
+
+// Character set names accepted by ToUTF8 and the -charset flags.
+const (
+	CharsetAuto  = "auto"  // MARC-8, if leader/09 is blank, UTF-8 otherwise
+	CharsetMARC8 = "marc8" // always decode MARC-8
+	CharsetUTF8  = "utf8"  // leave values as they are
+)
+
+// IsCharset returns true, if s is a valid charset name. The empty string is
+// valid and means no conversion.
+func IsCharset(s string) bool {
+	switch s {
+	case "", CharsetAuto, CharsetMARC8, CharsetUTF8:
+		return true
+	}
+	return false
+}
+
+// marc8Set is a single graphic character set, designated by a final
+// character in an escape sequence. Codes are stored without the high bit, so
+// a set can be designated as G0 or G1.
+type marc8Set struct {
+	name      string
+	final     string
+	multibyte bool
+	codes     map[int]rune
+}
+
+var (
+	marc8Mu     sync.RWMutex
+	marc8Sets   = make(map[string]*marc8Set) // by final character
+	marc8Encode = make(map[rune][]marc8Code) // reverse lookup
+	marc8Comp   = make(map[[2]rune]rune)     // base, mark => composed
+	marc8Decomp = make(map[rune][2]rune)     // composed => base, mark
+
+	marc8ASCII, marc8ANSEL *marc8Set
+
+	// numeric character references, used for characters not in MARC-8
+	marc8NCR = regexp.MustCompile(`&#x([0-9A-Fa-f]{4,6});`)
+)
+
+// marc8Code is the position of a character in a set
+type marc8Code struct {
+	set  *marc8Set
+	code int
+}
+
+// Final characters of the MARC-8 character sets. The first three are used
+// with the escape technique 1 (ESC g, ESC b, ESC p).
+const (
+	finalGreekSymbols   = "g"
+	finalSubscript      = "b"
+	finalSuperscript    = "p"
+	finalASCII          = "B"
+	finalANSEL          = "!E"
+	finalBasicCyrillic  = "N"
+	finalExtCyrillic    = "Q"
+	finalBasicGreek     = "S"
+	finalBasicHebrew    = "2"
+	finalBasicArabic    = "3"
+	finalExtArabic      = "4"
+	finalEACC           = "1"
+	escapeToASCII       = 's'
+	escapeDesignateG0   = '('
+	escapeDesignateG0Mb = '$'
+)
+
+func init() {
+	ascii := make(map[int]rune)
+	for c := 0x21; c < 0x7F; c++ {
+		ascii[c] = rune(c)
+	}
+	// sets, that share the ASCII punctuation and digits
+	withASCII := func(m map[int]rune, lo, hi int) map[int]rune {
+		for c := lo; c <= hi; c++ {
+			if _, ok := m[c]; !ok {
+				m[c] = rune(c)
+			}
+		}
+		return m
+	}
+
+	marc8ASCII = registerMARC8Set("Basic Latin (ASCII)", finalASCII, false, ascii)
+	marc8ANSEL = registerMARC8Set("Extended Latin (ANSEL)", finalANSEL, false, map[int]rune{
+		0x21: 0x0141, 0x22: 0x00D8, 0x23: 0x0110, 0x24: 0x00DE, 0x25: 0x00C6, 0x26: 0x0152,
+		0x27: 0x02B9, 0x28: 0x00B7, 0x29: 0x266D, 0x2A: 0x00AE, 0x2B: 0x00B1, 0x2C: 0x01A0,
+		0x2D: 0x01AF, 0x2E: 0x02BC, 0x30: 0x02BB, 0x31: 0x0142, 0x32: 0x00F8, 0x33: 0x0111,
+		0x34: 0x00FE, 0x35: 0x00E6, 0x36: 0x0153, 0x37: 0x02BA, 0x38: 0x0131, 0x39: 0x00A3,
+		0x3A: 0x00F0, 0x3C: 0x01A1, 0x3D: 0x01B0, 0x40: 0x00B0, 0x41: 0x2113, 0x42: 0x2117,
+		0x43: 0x00A9, 0x44: 0x266F, 0x45: 0x00BF, 0x46: 0x00A1, 0x47: 0x00DF, 0x48: 0x20AC,
+		// combining characters, which precede the base character in MARC-8
+		0x60: 0x0309, 0x61: 0x0300, 0x62: 0x0301, 0x63: 0x0302, 0x64: 0x0303, 0x65: 0x0304,
+		0x66: 0x0306, 0x67: 0x0307, 0x68: 0x0308, 0x69: 0x030C, 0x6A: 0x030A, 0x6B: 0xFE20,
+		0x6C: 0xFE21, 0x6D: 0x0315, 0x6E: 0x030B, 0x6F: 0x0310, 0x70: 0x0327, 0x71: 0x0328,
+		0x72: 0x0323, 0x73: 0x0324, 0x74: 0x0325, 0x75: 0x0333, 0x76: 0x0332, 0x77: 0x0326,
+		0x78: 0x031C, 0x79: 0x032E, 0x7A: 0xFE22, 0x7B: 0xFE23, 0x7E: 0x0313,
+	})
+	registerMARC8Set("Greek Symbols", finalGreekSymbols, false, map[int]rune{
+		0x61: 0x03B1, 0x62: 0x03B2, 0x63: 0x03B3,
+	})
+	registerMARC8Set("Subscripts", finalSubscript, false, map[int]rune{
+		0x28: 0x208D, 0x29: 0x208E, 0x2B: 0x208A, 0x2D: 0x208B,
+		0x30: 0x2080, 0x31: 0x2081, 0x32: 0x2082, 0x33: 0x2083, 0x34: 0x2084,
+		0x35: 0x2085, 0x36: 0x2086, 0x37: 0x2087, 0x38: 0x2088, 0x39: 0x2089,
+	})
+	registerMARC8Set("Superscripts", finalSuperscript, false, map[int]rune{
+		0x28: 0x207D, 0x29: 0x207E, 0x2B: 0x207A, 0x2D: 0x207B,
+		0x30: 0x2070, 0x31: 0x00B9, 0x32: 0x00B2, 0x33: 0x00B3, 0x34: 0x2074,
+		0x35: 0x2075, 0x36: 0x2076, 0x37: 0x2077, 0x38: 0x2078, 0x39: 0x2079,
+	})
+	registerMARC8Set("Basic Cyrillic", finalBasicCyrillic, false, withASCII(map[int]rune{
+		0x40: 0x044E, 0x41: 0x0430, 0x42: 0x0431, 0x43: 0x0446, 0x44: 0x0434, 0x45: 0x0435,
+		0x46: 0x0444, 0x47: 0x0433, 0x48: 0x0445, 0x49: 0x0438, 0x4A: 0x0439, 0x4B: 0x043A,
+		0x4C: 0x043B, 0x4D: 0x043C, 0x4E: 0x043D, 0x4F: 0x043E, 0x50: 0x043F, 0x51: 0x044F,
+		0x52: 0x0440, 0x53: 0x0441, 0x54: 0x0442, 0x55: 0x0443, 0x56: 0x0436, 0x57: 0x0432,
+		0x58: 0x044C, 0x59: 0x044B, 0x5A: 0x0437, 0x5B: 0x0448, 0x5C: 0x044D, 0x5D: 0x0449,
+		0x5E: 0x0447, 0x5F: 0x044A, 0x60: 0x042E, 0x61: 0x0410, 0x62: 0x0411, 0x63: 0x0426,
+		0x64: 0x0414, 0x65: 0x0415, 0x66: 0x0424, 0x67: 0x0413, 0x68: 0x0425, 0x69: 0x0418,
+		0x6A: 0x0419, 0x6B: 0x041A, 0x6C: 0x041B, 0x6D: 0x041C, 0x6E: 0x041D, 0x6F: 0x041E,
+		0x70: 0x041F, 0x71: 0x042F, 0x72: 0x0420, 0x73: 0x0421, 0x74: 0x0422, 0x75: 0x0423,
+		0x76: 0x0416, 0x77: 0x0412, 0x78: 0x042C, 0x79: 0x042B, 0x7A: 0x0417, 0x7B: 0x0428,
+		0x7C: 0x042D, 0x7D: 0x0429, 0x7E: 0x0427,
+	}, 0x21, 0x3F))
+	registerMARC8Set("Extended Cyrillic", finalExtCyrillic, false, map[int]rune{
+		0x40: 0x0491, 0x41: 0x0452, 0x42: 0x0453, 0x43: 0x0454, 0x44: 0x0451, 0x45: 0x0455,
+		0x46: 0x0456, 0x47: 0x0457, 0x48: 0x0458, 0x49: 0x0459, 0x4A: 0x045A, 0x4B: 0x045B,
+		0x4C: 0x045C, 0x4D: 0x045E, 0x4E: 0x045F, 0x50: 0x0463, 0x51: 0x0473, 0x52: 0x0475,
+		0x53: 0x046B, 0x5B: 0x005B, 0x5D: 0x005D, 0x5F: 0x005F, 0x60: 0x0490, 0x61: 0x0402,
+		0x62: 0x0403, 0x63: 0x0404, 0x64: 0x0401, 0x65: 0x0405, 0x66: 0x0406, 0x67: 0x0407,
+		0x68: 0x0408, 0x69: 0x0409, 0x6A: 0x040A, 0x6B: 0x040B, 0x6C: 0x040C, 0x6D: 0x040E,
+		0x6E: 0x040F, 0x6F: 0x042A, 0x70: 0x0462, 0x71: 0x0472, 0x72: 0x0474, 0x73: 0x046A,
+	})
+	registerMARC8Set("Basic Greek", finalBasicGreek, false, map[int]rune{
+		0x21: 0x0300, 0x22: 0x0301, 0x23: 0x0308, 0x24: 0x0342, 0x25: 0x0313, 0x26: 0x0314,
+		0x27: 0x0345, 0x28: 0x0028, 0x29: 0x0029, 0x2A: 0x002A, 0x2B: 0x002B, 0x2C: 0x002C,
+		0x2D: 0x002D, 0x2E: 0x002E, 0x2F: 0x002F, 0x30: 0x00AB, 0x31: 0x00BB, 0x32: 0x201C,
+		0x33: 0x201D, 0x34: 0x0374, 0x35: 0x0375, 0x3B: 0x0387, 0x3F: 0x037E, 0x41: 0x0391,
+		0x42: 0x0392, 0x44: 0x0393, 0x45: 0x0394, 0x46: 0x0395, 0x47: 0x03DA, 0x48: 0x03DC,
+		0x49: 0x0396, 0x4A: 0x0397, 0x4B: 0x0398, 0x4C: 0x0399, 0x4D: 0x039A, 0x4E: 0x039B,
+		0x4F: 0x039C, 0x50: 0x039D, 0x51: 0x039E, 0x52: 0x039F, 0x53: 0x03A0, 0x54: 0x03DE,
+		0x55: 0x03A1, 0x56: 0x03A3, 0x58: 0x03A4, 0x59: 0x03A5, 0x5A: 0x03A6, 0x5B: 0x03A7,
+		0x5C: 0x03A8, 0x5D: 0x03A9, 0x5E: 0x03E0, 0x61: 0x03B1, 0x62: 0x03B2, 0x63: 0x03D0,
+		0x64: 0x03B3, 0x65: 0x03B4, 0x66: 0x03B5, 0x67: 0x03DB, 0x68: 0x03DD, 0x69: 0x03B6,
+		0x6A: 0x03B7, 0x6B: 0x03B8, 0x6C: 0x03B9, 0x6D: 0x03BA, 0x6E: 0x03BB, 0x6F: 0x03BC,
+		0x70: 0x03BD, 0x71: 0x03BE, 0x72: 0x03BF, 0x73: 0x03C0, 0x74: 0x03DF, 0x75: 0x03C1,
+		0x76: 0x03C3, 0x77: 0x03C2, 0x78: 0x03C4, 0x79: 0x03C5, 0x7A: 0x03C6, 0x7B: 0x03C7,
+		0x7C: 0x03C8, 0x7D: 0x03C9, 0x7E: 0x03E1,
+	})
+	hebrew := map[int]rune{
+		0x40: 0x05B0, 0x41: 0x05B1, 0x42: 0x05B2, 0x43: 0x05B3, 0x44: 0x05B4, 0x45: 0x05B5,
+		0x46: 0x05B6, 0x47: 0x05B7, 0x48: 0x05B8, 0x49: 0x05B9, 0x4A: 0x05BB, 0x4B: 0x05BC,
+		0x4C: 0x05BF, 0x4D: 0x05C1, 0x4E: 0xFB1E, 0x5B: 0x005B, 0x5D: 0x005D,
+		0x7B: 0x05F0, 0x7C: 0x05F1, 0x7D: 0x05F2,
+	}
+	for c := 0x60; c <= 0x7A; c++ {
+		hebrew[c] = rune(0x05D0 + c - 0x60) // alef to tav, including final forms
+	}
+	registerMARC8Set("Basic Hebrew", finalBasicHebrew, false, withASCII(hebrew, 0x21, 0x3F))
+	arabic := map[int]rune{
+		0x25: 0x066A, 0x2C: 0x060C, 0x3B: 0x061B, 0x3F: 0x061F, 0x5B: 0x005B, 0x5D: 0x005D,
+		0x73: 0x0671, 0x74: 0x0670,
+	}
+	for c := 0x30; c <= 0x39; c++ {
+		arabic[c] = rune(0x0660 + c - 0x30) // arabic-indic digits
+	}
+	for c := 0x41; c <= 0x5A; c++ {
+		arabic[c] = rune(0x0621 + c - 0x41) // hamza to ghain
+	}
+	for c := 0x60; c <= 0x72; c++ {
+		arabic[c] = rune(0x0640 + c - 0x60) // tatweel to sukun
+	}
+	registerMARC8Set("Basic Arabic", finalBasicArabic, false, withASCII(arabic, 0x21, 0x3F))
+	// mappings are generated into marc8_tables.go by gen_marc8tables.go, or
+	// loaded with LoadMARC8Tables
+	registerMARC8Set("Extended Arabic", finalExtArabic, false, map[int]rune{})
+	registerMARC8Set("East Asian (EACC)", finalEACC, true, map[int]rune{})
+
+	for _, c := range marc8Compositions {
+		marc8Comp[[2]rune{c[0], c[1]}] = c[2]
+		marc8Decomp[c[2]] = [2]rune{c[0], c[1]}
+	}
+}
+
+// registerMARC8Set adds a set and extends the reverse lookup table
+func registerMARC8Set(name, final string, multibyte bool, codes map[int]rune) *marc8Set {
+	set, ok := marc8Sets[final]
+	if !ok {
+		set = &marc8Set{name: name, final: final, multibyte: multibyte, codes: make(map[int]rune)}
+		marc8Sets[final] = set
+	}
+	for code, r := range codes {
+		set.codes[code] = r
+		marc8Encode[r] = append(marc8Encode[r], marc8Code{set: set, code: code})
+	}
+	return set
+}
+
+// codeTable mirrors the parts of the Library of Congress MARC-8 code tables
+// XML (http://www.loc.gov/marc/specifications/codetables.xml) that are used.
+type codeTable struct {
+	Name    string `xml:"name,attr"`
+	ISOCode string `xml:"ISOcode,attr"`
+	Codes   []struct {
+		MARC string `xml:"marc"`
+		UCS  string `xml:"ucs"`
+		Alt  string `xml:"alt"`
+	} `xml:"code"`
+}
+
+// LoadMARC8Tables reads additional mappings from the Library of Congress
+// code tables XML. The builtin tables cover all sets except Extended Arabic
+// and East Asian (EACC), for which the code tables must be loaded.
+func LoadMARC8Tables(r io.Reader) error {
+	decoder := xml.NewDecoder(r)
+	marc8Mu.Lock()
+	defer marc8Mu.Unlock()
+	for {
+		t, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "characterSet" {
+			continue
+		}
+		var table codeTable
+		if err := decoder.DecodeElement(&table, &se); err != nil {
+			return err
+		}
+		iso, err := strconv.ParseUint(table.ISOCode, 16, 8)
+		if err != nil {
+			return fmt.Errorf("code tables: %s: invalid ISO code: %q", table.Name, table.ISOCode)
+		}
+		final := string(rune(iso))
+		if final == "E" {
+			final = finalANSEL
+		}
+		set, ok := marc8Sets[final]
+		if !ok {
+			continue
+		}
+		codes := make(map[int]rune)
+		for _, c := range table.Codes {
+			ucs := c.UCS
+			if ucs == "" {
+				ucs = c.Alt
+			}
+			code, err := strconv.ParseUint(c.MARC, 16, 32)
+			if err != nil {
+				return fmt.Errorf("code tables: %s: invalid code: %q", table.Name, c.MARC)
+			}
+			r, err := strconv.ParseUint(ucs, 16, 32)
+			if err != nil {
+				return fmt.Errorf("code tables: %s: invalid ucs: %q", table.Name, ucs)
+			}
+			// strip the high bit of each byte
+			codes[int(code&0x7F7F7F)] = rune(r)
+		}
+		registerMARC8Set(set.name, final, set.multibyte, codes)
+	}
+}
+
+// isMark returns true for combining characters
+func isMark(r rune) bool {
+	return unicode.Is(unicode.Mn, r)
+}
+
+// parseMARC8Escape parses an escape sequence at the start of b and returns
+// the register (0 or 1), the designated set and the number of bytes used.
+func parseMARC8Escape(b []byte) (int, *marc8Set, int, error) {
+	if len(b) < 2 {
+		return 0, nil, len(b), fmt.Errorf("incomplete escape sequence")
+	}
+	var register, i int
+	switch b[1] {
+	case 'g', 'b', 'p':
+		return 0, marc8Sets[string(b[1:2])], 2, nil
+	case escapeToASCII:
+		return 0, marc8ASCII, 2, nil
+	case '(', ',':
+		i = 2
+	case ')', '-':
+		register, i = 1, 2
+	case escapeDesignateG0Mb:
+		i = 2
+		if len(b) > 2 {
+			switch b[2] {
+			case ',':
+				i = 3
+			case ')', '-':
+				register, i = 1, 3
+			}
+		}
+	default:
+		return 0, nil, 1, fmt.Errorf("unknown escape sequence: %q", b[:2])
+	}
+	if i >= len(b) {
+		return 0, nil, len(b), fmt.Errorf("incomplete escape sequence: %q", b)
+	}
+	final := string(b[i : i+1])
+	if b[i] == '!' && i+1 < len(b) {
+		final = string(b[i : i+2])
+	}
+	set, ok := marc8Sets[final]
+	if !ok {
+		return 0, nil, i + len(final), fmt.Errorf("unknown character set: %q", b[:i+len(final)])
+	}
+	return register, set, i + len(final), nil
+}
+
+// DecodeMARC8 converts MARC-8 encoded bytes into an UTF-8 string. Combining
+// characters are moved behind their base character and composed, where
+// possible. Undecodable characters are replaced by U+FFFD and the first
+// problem is reported as error, wrapping ErrInvalidMARC8.
+func DecodeMARC8(b []byte) (string, error) {
+	marc8Mu.RLock()
+	defer marc8Mu.RUnlock()
+
+	var (
+		g        = [2]*marc8Set{marc8ASCII, marc8ANSEL}
+		out      = make([]rune, 0, len(b))
+		marks    []rune
+		firstErr error
+	)
+	fail := func(format string, args ...interface{}) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%w: %s", ErrInvalidMARC8, fmt.Sprintf(format, args...))
+		}
+	}
+	emit := func(r rune) {
+		if isMark(r) {
+			marks = append(marks, r)
+			return
+		}
+		out = appendComposed(out, r, marks)
+		marks = marks[:0]
+	}
+
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0x1B:
+			register, set, n, err := parseMARC8Escape(b[i:])
+			if err != nil {
+				fail("%s", err)
+			} else {
+				g[register] = set
+			}
+			i += n
+		case c == 0x88:
+			// non-sort begin
+			emit(0x98)
+			i++
+		case c == 0x89:
+			// non-sort end
+			emit(0x9C)
+			i++
+		case c == 0x8D:
+			emit(0x200D)
+			i++
+		case c == 0x8E:
+			emit(0x200C)
+			i++
+		case (c > 0x20 && c < 0x7F) || (c > 0xA0 && c < 0xFF):
+			set := g[0]
+			if c > 0x7F {
+				set = g[1]
+			}
+			width := 1
+			if set.multibyte {
+				width = 3
+			}
+			if i+width > len(b) {
+				fail("incomplete character at %d", i)
+				emit(utf8.RuneError)
+				i = len(b)
+				continue
+			}
+			var code int
+			for _, v := range b[i : i+width] {
+				code = code<<8 | int(v&0x7F)
+			}
+			if r, ok := set.codes[code]; ok {
+				emit(r)
+			} else {
+				if len(set.codes) == 0 {
+					fail("no mapping for %X in %s, the code tables are not loaded (-codetables or gen_marc8tables.go)", b[i:i+width], set.name)
+				} else {
+					fail("no mapping for %X in %s", b[i:i+width], set.name)
+				}
+				emit(utf8.RuneError)
+			}
+			i += width
+		default:
+			// space, delimiters and other control characters
+			emit(rune(c))
+			i++
+		}
+	}
+	out = append(out, marks...)
+	s := string(out)
+	if strings.Contains(s, "&#x") {
+		s = marc8NCR.ReplaceAllStringFunc(s, func(ncr string) string {
+			v, err := strconv.ParseUint(ncr[3:len(ncr)-1], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(v)) {
+				return ncr
+			}
+			return string(rune(v))
+		})
+	}
+	return s, firstErr
+}
+
+// appendComposed appends a base character followed by combining marks,
+// composing them as far as possible.
+func appendComposed(out []rune, base rune, marks []rune) []rune {
+	i := 0
+	for ; i < len(marks); i++ {
+		composed, ok := marc8Comp[[2]rune{base, marks[i]}]
+		if !ok {
+			break
+		}
+		base = composed
+	}
+	out = append(out, base)
+	return append(out, marks[i:]...)
+}
+
+// marc8Encoder keeps track of the designated set.
+type marc8Encoder struct {
+	buf bytes.Buffer
+	g0  *marc8Set
+}
+
+// designate switches G0 to the given set, if necessary
+func (e *marc8Encoder) designate(set *marc8Set) {
+	if e.g0 == set {
+		return
+	}
+	switch set.final {
+	case finalGreekSymbols, finalSubscript, finalSuperscript:
+		e.buf.Write([]byte{0x1B, set.final[0]})
+	case finalASCII:
+		if e.g0.final == finalGreekSymbols || e.g0.final == finalSubscript || e.g0.final == finalSuperscript {
+			e.buf.Write([]byte{0x1B, escapeToASCII})
+		} else {
+			e.buf.Write([]byte{0x1B, escapeDesignateG0, 'B'})
+		}
+	default:
+		e.buf.WriteByte(0x1B)
+		if set.multibyte {
+			e.buf.WriteByte(escapeDesignateG0Mb)
+		} else {
+			e.buf.WriteByte(escapeDesignateG0)
+		}
+		e.buf.WriteString(set.final)
+	}
+	e.g0 = set
+}
+
+// lookup returns the preferred code for a rune: ASCII and ANSEL first, then
+// the currently designated set, then any other set.
+func (e *marc8Encoder) lookup(r rune) (marc8Code, bool) {
+	codes := marc8Encode[r]
+	if len(codes) == 0 {
+		return marc8Code{}, false
+	}
+	for _, preferred := range []*marc8Set{marc8ASCII, marc8ANSEL, e.g0} {
+		for _, c := range codes {
+			if c.set == preferred {
+				return c, true
+			}
+		}
+	}
+	return codes[0], true
+}
+
+// write writes a single character, which must be encodable
+func (e *marc8Encoder) write(c marc8Code) {
+	switch {
+	case c.set == marc8ANSEL:
+		e.buf.WriteByte(byte(c.code) | 0x80)
+	case c.set.multibyte:
+		e.designate(c.set)
+		e.buf.Write([]byte{byte(c.code >> 16), byte(c.code >> 8), byte(c.code)})
+	default:
+		e.designate(c.set)
+		e.buf.WriteByte(byte(c.code))
+	}
+}
+
+// writeNCR writes a numeric character reference in ASCII
+func (e *marc8Encoder) writeNCR(r rune) {
+	e.designate(marc8ASCII)
+	fmt.Fprintf(&e.buf, "&#x%04X;", r)
+}
+
+// decompose splits a character into an encodable base and combining marks
+func (e *marc8Encoder) decompose(r rune) (rune, []rune) {
+	if _, ok := e.lookup(r); ok {
+		return r, nil
+	}
+	if d, ok := marc8Decomp[r]; ok {
+		base, marks := e.decompose(d[0])
+		return base, append(marks, d[1])
+	}
+	return r, nil
+}
+
+// EncodeMARC8 converts an UTF-8 string into MARC-8. Combining marks are
+// placed before their base character. Characters, that cannot be expressed
+// in MARC-8 are written as numeric character references (&#xXXXX;), as
+// recommended by the Library of Congress.
+func EncodeMARC8(s string) []byte {
+	marc8Mu.RLock()
+	defer marc8Mu.RUnlock()
+
+	e := &marc8Encoder{g0: marc8ASCII}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		i++
+		if r < 0x20 || r == 0x7F {
+			e.buf.WriteByte(byte(r))
+			continue
+		}
+		if r == 0x20 {
+			// space is the same in all sets
+			e.buf.WriteByte(0x20)
+			continue
+		}
+		switch r {
+		case 0x98:
+			e.buf.WriteByte(0x88)
+			continue
+		case 0x9C:
+			e.buf.WriteByte(0x89)
+			continue
+		case 0x200D:
+			e.buf.WriteByte(0x8D)
+			continue
+		case 0x200C:
+			e.buf.WriteByte(0x8E)
+			continue
+		}
+		base, marks := e.decompose(r)
+		for i < len(runes) && isMark(runes[i]) {
+			marks = append(marks, runes[i])
+			i++
+		}
+		for _, m := range marks {
+			if c, ok := e.lookup(m); ok {
+				e.write(c)
+			} else {
+				e.writeNCR(m)
+			}
+		}
+		if c, ok := e.lookup(base); ok {
+			e.write(c)
+		} else {
+			e.writeNCR(base)
+		}
+	}
+	e.designate(marc8ASCII)
+	return e.buf.Bytes()
+}
+
+// isMARC8 returns true, if the record is MARC-8 encoded according to its
+// leader, position 09.
+func isMARC8(record *marc22.Record) bool {
+	if record.LeaderParsed != nil {
+		return record.LeaderParsed.CharacterEncoding == ' '
+	}
+	return len(record.Leader) == 24 && record.Leader[9] == ' '
+}
+
+// setCharacterEncoding sets leader/09 in both parsed and raw leader.
+func setCharacterEncoding(record *marc22.Record, cs byte) {
+	if record.LeaderParsed != nil {
+		record.LeaderParsed.CharacterEncoding = cs
+	}
+	if len(record.Leader) == 24 {
+		record.Leader = record.Leader[:9] + string(cs) + record.Leader[10:]
+	}
+}
+
+// ToUTF8 converts all values of a MARC-8 record into UTF-8, in place, and
+// sets leader/09 to 'a'. With CharsetAuto only records with a blank leader/09
+// are converted, CharsetMARC8 converts regardless of the leader. The record
+// is converted completely, even if an error occurs; the first error is
+// returned.
+func ToUTF8(record *marc22.Record, charset string) error {
+	switch charset {
+	case "", CharsetUTF8:
+		return nil
+	case CharsetAuto:
+		if !isMARC8(record) {
+			return nil
+		}
+	case CharsetMARC8:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownCharset, charset)
+	}
+	var firstErr error
+	decode := func(tag, s string) string {
+		v, err := DecodeMARC8([]byte(s))
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", tag, err)
+		}
+		return v
+	}
+	for i := range record.ControlFields {
+		f := &record.ControlFields[i]
+		f.Data = decode(f.Tag, f.Data)
+	}
+	for i := range record.DataFields {
+		f := &record.DataFields[i]
+		for _, sf := range f.SubFields {
+			sf.Value = decode(f.Tag, sf.Value)
+		}
+	}
+	setCharacterEncoding(record, 'a')
+	return firstErr
+}
+
+// ToMARC8 converts all values of an UTF-8 record into MARC-8, in place, and
+// sets leader/09 to blank. Records, that are already MARC-8 are left as is.
+func ToMARC8(record *marc22.Record) {
+	if isMARC8(record) {
+		return
+	}
+	for i := range record.ControlFields {
+		f := &record.ControlFields[i]
+		f.Data = string(EncodeMARC8(f.Data))
+	}
+	for i := range record.DataFields {
+		for _, sf := range record.DataFields[i].SubFields {
+			sf.Value = string(EncodeMARC8(sf.Value))
+		}
+	}
+	setCharacterEncoding(record, ' ')
+}
@@ -0,0 +1,202 @@
+package marctools
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+var marc8Tests = []struct {
+	marc8 string
+	utf8  string
+}{
+	{"plain ASCII", "plain ASCII"},
+	{"Caf\xe2e", "Café"},
+	{"\xa1\xe2odz", "Łódz"},
+	{"\xe3\xe2e", "ế"},
+	{"Stra\xc7e", "Straße"},
+	{"\x1b(NrUSSKIJ\x1b(B text", "Русский text"},
+	{"\x1b(QD\x1b(B", "ё"}, {"\x1b(Qd\x1b(B", "Ё"},
+	{"m\x1bp2\x1bs", "m²"},
+	{"H\x1bb2\x1bsO", "H₂O"},
+	{"\x1bga\x1bs-helix", "α-helix"},
+	{"\x1b(2`a\x1b(B", "אב"},
+	{"\x1b(3HQ\x1b(B", "بر"},
+	{"\x88The\x89 title", "\u0098The\u009c title"},
+}
+
+func TestDecodeMARC8(t *testing.T) {
+	for _, tt := range marc8Tests {
+		s, err := DecodeMARC8([]byte(tt.marc8))
+		if err != nil {
+			t.Errorf("DecodeMARC8(%q) => %s", tt.marc8, err)
+		}
+		if s != tt.utf8 {
+			t.Errorf("DecodeMARC8(%q) => %q, want: %q", tt.marc8, s, tt.utf8)
+		}
+	}
+
+	s, err := DecodeMARC8([]byte("&#x4E2D;"))
+	if err != nil || s != "中" {
+		t.Errorf("DecodeMARC8(NCR) => %q, %v, want: %q", s, err, "中")
+	}
+
+	for _, in := range []string{"\x1b(Zabc", "\x1b$1\x21\x30\x22", "\x1b(N\x1b"} {
+		if _, err := DecodeMARC8([]byte(in)); !errors.Is(err, ErrInvalidMARC8) {
+			t.Errorf("DecodeMARC8(%q) => %v, want: %v", in, err, ErrInvalidMARC8)
+		}
+	}
+}
+
+func TestEncodeMARC8(t *testing.T) {
+	for _, tt := range marc8Tests {
+		b := EncodeMARC8(tt.utf8)
+		s, err := DecodeMARC8(b)
+		if err != nil || s != tt.utf8 {
+			t.Errorf("DecodeMARC8(EncodeMARC8(%q)) => %q, %v", tt.utf8, s, err)
+		}
+	}
+	var tests = []struct {
+		in  string
+		out string
+	}{
+		{"Café", "Caf\xe2e"},
+		{"Café", "Caf\xe2e"},
+		{"Русский text", "\x1b(NrUSSKIJ \x1b(Btext"},
+		{"m²", "m\x1bp2\x1bs"},
+		{"中", "&#x4E2D;"},
+	}
+	for _, tt := range tests {
+		if b := EncodeMARC8(tt.in); string(b) != tt.out {
+			t.Errorf("EncodeMARC8(%q) => %q, want: %q", tt.in, b, tt.out)
+		}
+	}
+}
+
+func TestLoadMARC8Tables(t *testing.T) {
+	tables := `<?xml version="1.0" encoding="UTF-8"?>
+<codeTables>
+  <codeTable name="East Asian Ideographs" number="11">
+    <characterSet ISOcode="31" name="East Asian Ideographs">
+      <code><marc>213021</marc><ucs>4E00</ucs><utf-8>E4B880</utf-8><name/></code>
+    </characterSet>
+  </codeTable>
+</codeTables>`
+	if err := LoadMARC8Tables(strings.NewReader(tables)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := DecodeMARC8([]byte("\x1b$1\x21\x30\x21\x1b(B!"))
+	if err != nil || s != "一!" {
+		t.Errorf("DecodeMARC8(EACC) => %q, %v, want: %q", s, err, "一!")
+	}
+	if b := EncodeMARC8("一!"); string(b) != "\x1b$1\x21\x30\x21\x1b(B!" {
+		t.Errorf("EncodeMARC8(EACC) => %q", b)
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	record := &marc22.Record{
+		Leader:        "00000nam  2200000   4500",
+		LeaderParsed:  &marc22.Leader{CharacterEncoding: ' '},
+		ControlFields: []marc22.ControlField{{Tag: "001", Data: "123"}},
+		DataFields: []marc22.DataField{{Tag: "245", Ind1: "1", Ind2: "0",
+			SubFields: []*marc22.SubField{{Code: "a", Value: "Caf\xe2e"}}}},
+	}
+	if err := ToUTF8(record, CharsetUTF8); err != nil {
+		t.Fatal(err)
+	}
+	if v := record.DataFields[0].SubFields[0].Value; v != "Caf\xe2e" {
+		t.Errorf("ToUTF8(utf8) => %q, want: unchanged", v)
+	}
+	if err := ToUTF8(record, CharsetAuto); err != nil {
+		t.Fatal(err)
+	}
+	if v := record.DataFields[0].SubFields[0].Value; v != "Café" {
+		t.Errorf("ToUTF8(auto) => %q, want: %q", v, "Café")
+	}
+	if record.LeaderParsed.CharacterEncoding != 'a' || record.Leader[9] != 'a' {
+		t.Errorf("ToUTF8(auto) => leader/09 %q, want: 'a'", record.Leader[9])
+	}
+	// already UTF-8, nothing to do
+	if err := ToUTF8(record, CharsetAuto); err != nil || record.DataFields[0].SubFields[0].Value != "Café" {
+		t.Errorf("ToUTF8(auto) on UTF-8 => %q, %v", record.DataFields[0].SubFields[0].Value, err)
+	}
+
+	ToMARC8(record)
+	if v := record.DataFields[0].SubFields[0].Value; v != "Caf\xe2e" || record.Leader[9] != ' ' {
+		t.Errorf("ToMARC8() => %q, leader/09 %q", v, record.Leader[9])
+	}
+
+	if err := ToUTF8(record, "latin1"); !errors.Is(err, ErrUnknownCharset) {
+		t.Errorf("ToUTF8(latin1) => %v, want: %v", err, ErrUnknownCharset)
+	}
+}
+
+func TestMarshalRecordLeaderConverted(t *testing.T) {
+	newRecord := func() *marc22.Record {
+		leader, err := parseLeaderTemplate("00000nam  2200000   45 0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &marc22.Record{
+			Leader:       "00000nam  2200000   45 0",
+			LeaderParsed: leader,
+			DataFields: []marc22.DataField{{Tag: "245", Ind1: "1", Ind2: "0",
+				SubFields: []*marc22.SubField{{Code: "a", Value: "Caf\xe2e"}}}},
+		}
+	}
+	// converted records are marked as UTF-8, the raw leader is kept otherwise
+	b, err := MarshalRecord(newRecord(), JSONConversionOptions{IncludeLeader: true, Charset: CharsetAuto, PlainMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"cs":"a"`, `"raw":"00000nam a2200000   45 0"`, `"Café"`} {
+		if !strings.Contains(string(b), s) {
+			t.Errorf("MarshalRecord => %s, want: %s", b, s)
+		}
+	}
+	record, err := UnmarshalRecordMap(b, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Leader[9] != 'a' || record.DataFields[0].SubFields[0].Value != "Café" {
+		t.Errorf("UnmarshalRecordMap => leader %q, value %q", record.Leader, record.DataFields[0].SubFields[0].Value)
+	}
+	b, err = MarshalRecord(newRecord(), JSONConversionOptions{Format: FormatMIJ, Charset: CharsetAuto})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"leader":"00000nam a2200000   45 0"`) {
+		t.Errorf("MarshalRecord(mij) => %s, want: leader/09 a", b)
+	}
+}
+
+func TestDecodeMARC8GeneratedTables(t *testing.T) {
+	var tests = []struct {
+		final string
+		marc8 string
+		utf8  string
+	}{
+		{finalEACC, "\x1b$1\x21\x30\x21\x1b(B", "\u4e00"},
+		{finalExtArabic, "\x1b)4\xa1\x1b(B", "\u06fd"},
+	}
+	for _, tt := range tests {
+		marc8Mu.RLock()
+		n := len(marc8Sets[tt.final].codes)
+		marc8Mu.RUnlock()
+		if n == 0 {
+			// without marc8_tables.go, decoding fails with a hint
+			if _, err := DecodeMARC8([]byte(tt.marc8)); err == nil || !strings.Contains(err.Error(), "code tables") {
+				t.Errorf("DecodeMARC8(%q) => %v, want: error about code tables", tt.marc8, err)
+			}
+			t.Logf("%s: no code tables, run gen_marc8tables.go", marc8Sets[tt.final].name)
+			continue
+		}
+		s, err := DecodeMARC8([]byte(tt.marc8))
+		if err != nil || s != tt.utf8 {
+			t.Errorf("DecodeMARC8(%q) => %q, %v, want: %q", tt.marc8, s, err, tt.utf8)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package marctools
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// Computed meta fields, cf. ParseMetaSpec
+const (
+	MetaFilename = "filename" // name of the source file
+	MetaOffset   = "offset"   // byte offset of the record in the source file
+	MetaLength   = "length"   // length of the record in bytes
+	MetaSeq      = "seq"      // zero-based number of the record in the input
+	MetaSHA1     = "sha1"     // SHA-1 of the raw record, hex encoded
+	MetaStatus   = "status"   // record status, leader/05
+)
+
+// metaNames are the names of the computed meta fields
+var metaNames = []string{MetaFilename, MetaOffset, MetaLength, MetaSeq, MetaSHA1, MetaStatus}
+
+// RecordOrigin describes where a record was found in the input. Offset,
+// length and data are only known for binary MARC.
+type RecordOrigin struct {
+	Filename string
+	Seq      int64  // zero-based number of the record over all inputs
+	Offset   int64  // byte offset of the record, -1 if unknown
+	Length   int64  // length of the record, -1 if unknown
+	Data     []byte // the raw record, nil if unknown
+}
+
+// NewRecordOrigin returns the origin of a raw record, numbered seq.
+func NewRecordOrigin(rr *RawRecord, seq int64) *RecordOrigin {
+	return &RecordOrigin{Filename: rr.Filename, Seq: seq, Offset: rr.Offset, Length: rr.Length, Data: rr.Data}
+}
+
+// MetaField is a single meta value, either computed from the origin of the
+// record (Name) or taken from a field (Spec).
+type MetaField struct {
+	Key  string // key in the meta object
+	Name string // a computed field, e.g. MetaOffset
+	Spec *IdentifierSpec
+}
+
+// String returns the field in the notation used by ParseMetaSpec.
+func (f MetaField) String() string {
+	if f.Spec != nil {
+		return f.Key + "=" + f.Spec.String()
+	}
+	return f.Name
+}
+
+// MetaSpec describes the meta values computed for each record.
+type MetaSpec struct {
+	Fields []MetaField
+}
+
+// ParseMetaSpec parses a comma separated list of meta fields. A field is
+// either the name of a computed value (filename, offset, length, seq, sha1
+// or status) or key=field, where field is given as in ParseIdentifierSpec,
+// e.g. "filename,offset,date=005,isbn=020.a".
+func ParseMetaSpec(s string) (*MetaSpec, error) {
+	spec := &MetaSpec{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if i := strings.Index(p, "="); i >= 0 {
+			key := strings.TrimSpace(p[:i])
+			if key == "" {
+				return nil, fmt.Errorf("%w: %q: missing key in %q", ErrInvalidMetaSpec, s, p)
+			}
+			fs, err := ParseIdentifierSpec(p[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q: %v", ErrInvalidMetaSpec, s, err)
+			}
+			spec.Fields = append(spec.Fields, MetaField{Key: key, Spec: fs})
+			continue
+		}
+		if !isTag(p, metaNames...) {
+			return nil, fmt.Errorf("%w: %q: unknown field %q", ErrInvalidMetaSpec, s, p)
+		}
+		spec.Fields = append(spec.Fields, MetaField{Key: p, Name: p})
+	}
+	return spec, nil
+}
+
+// String returns the specification in the notation used by ParseMetaSpec.
+func (spec *MetaSpec) String() string {
+	var fields []string
+	for _, f := range spec.Fields {
+		fields = append(fields, f.String())
+	}
+	return strings.Join(fields, ",")
+}
+
+// NeedsRaw reports whether any field requires offset, length or the raw
+// bytes of a record, which are only available for binary MARC.
+func (spec *MetaSpec) NeedsRaw() bool {
+	for _, f := range spec.Fields {
+		if isTag(f.Name, MetaOffset, MetaLength, MetaSHA1) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns the meta values of a record, with numbers for offset,
+// length and seq. Fields, that are missing in the record, are omitted.
+// Origin may be nil, if the record was not read from a file.
+func (spec *MetaSpec) Values(record *marc22.Record, origin *RecordOrigin) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, f := range spec.Fields {
+		if f.Spec != nil {
+			v, err := f.Spec.Identifier(record)
+			if errors.Is(err, ErrMissingIdentifier) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[f.Key] = v
+			continue
+		}
+		if f.Name == MetaStatus {
+			values[f.Key] = string(leaderByte(record, 5))
+			continue
+		}
+		if origin == nil {
+			return nil, fmt.Errorf("meta %s: origin of record unknown", f.Name)
+		}
+		switch f.Name {
+		case MetaFilename:
+			values[f.Key] = origin.Filename
+		case MetaSeq:
+			values[f.Key] = origin.Seq
+		case MetaOffset:
+			if origin.Offset < 0 {
+				return nil, fmt.Errorf("meta %s: offset of record unknown", f.Name)
+			}
+			values[f.Key] = origin.Offset
+		case MetaLength:
+			if origin.Length < 0 {
+				return nil, fmt.Errorf("meta %s: length of record unknown", f.Name)
+			}
+			values[f.Key] = origin.Length
+		case MetaSHA1:
+			if origin.Data == nil {
+				return nil, fmt.Errorf("meta %s: raw record unknown", f.Name)
+			}
+			sum := sha1.Sum(origin.Data)
+			values[f.Key] = hex.EncodeToString(sum[:])
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,119 @@
+package marctools
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetaSpec(t *testing.T) {
+	var tests = []struct {
+		in   string
+		spec *MetaSpec
+		err  error
+	}{
+		{"offset", &MetaSpec{Fields: []MetaField{{Key: "offset", Name: MetaOffset}}}, nil},
+		{"filename,seq,date=005,isbn=020.a", &MetaSpec{Fields: []MetaField{
+			{Key: "filename", Name: MetaFilename},
+			{Key: "seq", Name: MetaSeq},
+			{Key: "date", Spec: &IdentifierSpec{Fields: []IdentifierField{{Tag: "005"}}}},
+			{Key: "isbn", Spec: &IdentifierSpec{Fields: []IdentifierField{{Tag: "020", Code: "a"}}}},
+		}}, nil},
+		{"id=035.a=(DE-576);strip", &MetaSpec{Fields: []MetaField{
+			{Key: "id", Spec: &IdentifierSpec{Fields: []IdentifierField{{Tag: "035", Code: "a", Prefix: "(DE-576)"}}, StripPrefix: true}},
+		}}, nil},
+		{"", nil, ErrInvalidMetaSpec},
+		{"md5", nil, ErrInvalidMetaSpec},
+		{"=005", nil, ErrInvalidMetaSpec},
+		{"date=245", nil, ErrInvalidMetaSpec},
+	}
+
+	for _, tt := range tests {
+		spec, err := ParseMetaSpec(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseMetaSpec(%q) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if !reflect.DeepEqual(spec, tt.spec) {
+			t.Errorf("ParseMetaSpec(%q) => %+v, want: %+v", tt.in, spec, tt.spec)
+		}
+		if err == nil && spec.String() != tt.in {
+			t.Errorf("ParseMetaSpec(%q).String() => %q", tt.in, spec.String())
+		}
+	}
+}
+
+func TestMetaSpecValues(t *testing.T) {
+	file, err := os.Open("./fixtures/issue-5.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := NewReader(file)
+	reader.Filename = "issue-5.mrc"
+	var origins []*RecordOrigin
+	for {
+		rr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		origins = append(origins, NewRecordOrigin(rr, int64(len(origins))))
+	}
+
+	spec, err := ParseMetaSpec("filename,offset,length,seq,sha1,status,id=003+001;sep=:,isbn=020.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		origin *RecordOrigin
+		values map[string]interface{}
+	}{
+		{origins[1], map[string]interface{}{
+			"filename": "issue-5.mrc",
+			"offset":   int64(1033),
+			"length":   int64(561),
+			"seq":      int64(1),
+			"sha1":     "57297b82d009da22bb17290d28670faed1ff2faa",
+			"status":   "n",
+			"id":       "SIRSI:u1033899",
+		}},
+	}
+	for _, tt := range tests {
+		record, err := ParseRecord(tt.origin.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := spec.Values(record, tt.origin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("Values => %v, want: %v", values, tt.values)
+		}
+	}
+
+	// without raw data, only status and fields are available
+	record, err := ParseRecord(origins[0].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := spec.Values(record, nil); err == nil {
+		t.Errorf("Values without origin => nil, want: error")
+	}
+	spec, err = ParseMetaSpec("status,date=005")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := spec.Values(record, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"status": "c", "date": "20040220111428.0"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Values => %v, want: %v", values, want)
+	}
+}
@@ -0,0 +1,206 @@
+package marctools
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+
+	"github.com/miku/marc22"
+)
+
+// MARC-in-JSON, cf. https://github.com/marc4j/marc4j/wiki/MARC-in-JSON-Description
+type mijRecord struct {
+	Leader string                       `json:"leader"`
+	Fields []map[string]json.RawMessage `json:"fields"`
+}
+
+type mijDataField struct {
+	Ind1      string              `json:"ind1"`
+	Ind2      string              `json:"ind2"`
+	Subfields []map[string]string `json:"subfields"`
+}
+
+// mijIndicator returns a blank for empty indicators
+func mijIndicator(s string) string {
+	if s == "" {
+		return " "
+	}
+	return s
+}
+
+// MarshalMIJ serializes a record to MARC-in-JSON. If filter is not empty,
+// only the given tags are included.
+func MarshalMIJ(record *marc22.Record, filter map[string]bool) ([]byte, error) {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := mijFields(record, filter)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mijRecord{Leader: string(leader), Fields: fields})
+}
+
+// mijFields returns the fields of a record in MARC-in-JSON notation, in
+// record order, optionally only the tags given in filter
+func mijFields(record *marc22.Record, filter map[string]bool) ([]map[string]json.RawMessage, error) {
+	included := func(tag string) bool {
+		return len(filter) == 0 || filter[tag]
+	}
+	fields := make([]map[string]json.RawMessage, 0, len(record.ControlFields)+len(record.DataFields))
+	for _, field := range record.ControlFields {
+		if !included(field.Tag) {
+			continue
+		}
+		b, err := json.Marshal(field.Data)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, map[string]json.RawMessage{field.Tag: b})
+	}
+	for _, field := range record.DataFields {
+		if !included(field.Tag) {
+			continue
+		}
+		df := mijDataField{
+			Ind1:      mijIndicator(field.Ind1),
+			Ind2:      mijIndicator(field.Ind2),
+			Subfields: make([]map[string]string, 0, len(field.SubFields)),
+		}
+		for _, subfield := range field.SubFields {
+			df.Subfields = append(df.Subfields, map[string]string{subfield.Code: subfield.Value})
+		}
+		b, err := json.Marshal(df)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, map[string]json.RawMessage{field.Tag: b})
+	}
+	return fields, nil
+}
+
+// UnmarshalMIJ parses a single MARC-in-JSON record. Record length and base
+// address in the leader are not checked, since they are recomputed on
+// serialization anyway.
+func UnmarshalMIJ(b []byte) (*marc22.Record, error) {
+	var r mijRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidRecord, err)
+	}
+	return r.record()
+}
+
+// record converts the MARC-in-JSON structure into a record
+func (r *mijRecord) record() (*marc22.Record, error) {
+	parsed, err := parseLeaderTemplate(r.Leader)
+	if err != nil {
+		return nil, err
+	}
+	record := &marc22.Record{Leader: r.Leader, LeaderParsed: parsed}
+	if err := addMIJFields(record, r.Fields); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// addMIJFields appends fields in MARC-in-JSON notation to a record
+func addMIJFields(record *marc22.Record, fields []map[string]json.RawMessage) error {
+	for _, field := range fields {
+		if len(field) != 1 {
+			return fmt.Errorf("%w: field must have a single tag, got %d", ErrInvalidRecord, len(field))
+		}
+		for tag, value := range field {
+			if len(value) > 0 && value[0] == '"' {
+				var data string
+				if err := json.Unmarshal(value, &data); err != nil {
+					return fmt.Errorf("%w: %s: %s", ErrInvalidRecord, tag, err)
+				}
+				record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: tag, Data: data})
+				continue
+			}
+			var df mijDataField
+			if err := json.Unmarshal(value, &df); err != nil {
+				return fmt.Errorf("%w: %s: %s", ErrInvalidRecord, tag, err)
+			}
+			field := marc22.DataField{Tag: tag, Ind1: df.Ind1, Ind2: df.Ind2}
+			for _, subfield := range df.Subfields {
+				if len(subfield) != 1 {
+					return fmt.Errorf("%w: %s: subfield must have a single code, got %d", ErrInvalidRecord, tag, len(subfield))
+				}
+				for code, value := range subfield {
+					field.SubFields = append(field.SubFields, &marc22.SubField{Code: code, Value: value})
+				}
+			}
+			record.DataFields = append(record.DataFields, field)
+		}
+	}
+	return nil
+}
+
+// MIJReader reads MARC-in-JSON records from a stream. Records may be given
+// one per line, as a sequence of objects, or as a single JSON array.
+type MIJReader struct {
+	// Filename is used in errors, if not empty.
+	Filename string
+
+	dec   *json.Decoder
+	array bool
+	index int64
+	err   error
+}
+
+// NewMIJReader returns a reader, that reads from r.
+func NewMIJReader(r io.Reader) *MIJReader {
+	br := bufio.NewReader(r)
+	reader := &MIJReader{dec: json.NewDecoder(br)}
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			break
+		}
+		if !unicode.IsSpace(c) {
+			reader.array = c == '['
+			br.UnreadRune()
+			break
+		}
+	}
+	if reader.array {
+		_, reader.err = reader.dec.Token()
+	}
+	return reader
+}
+
+// Next returns the next record or io.EOF. Invalid records are reported as
+// *RecordError and reading can continue. Other errors, e.g. JSON syntax
+// errors, are final, later calls return the same error.
+func (r *MIJReader) Next() (*marc22.Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.array && !r.dec.More() {
+		r.err = io.EOF
+		return nil, r.err
+	}
+	var mr mijRecord
+	if err := r.dec.Decode(&mr); err != nil {
+		switch {
+		case err == io.EOF:
+			r.err = err
+		case r.Filename != "":
+			r.err = fmt.Errorf("%s: record %d: %w: %s", r.Filename, r.index, ErrInvalidRecord, err)
+		default:
+			r.err = fmt.Errorf("record %d: %w: %s", r.index, ErrInvalidRecord, err)
+		}
+		return nil, r.err
+	}
+	index := r.index
+	r.index++
+	record, err := mr.record()
+	if err != nil {
+		return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Err: err}
+	}
+	return record, nil
+}
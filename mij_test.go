@@ -0,0 +1,126 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMIJRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines bytes.Buffer
+		var data [][]byte
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := MarshalMIJ(record, nil)
+			if err != nil {
+				t.Fatalf("MarshalMIJ(%s, %d) => %s", filename, rr.Index, err)
+			}
+			lines.Write(b)
+			lines.WriteString("\n")
+			data = append(data, rr.Data)
+		}
+		file.Close()
+
+		mr := NewMIJReader(&lines)
+		for i := 0; ; i++ {
+			record, err := mr.Next()
+			if err == io.EOF {
+				if i != len(data) {
+					t.Errorf("%s: got %d records, want: %d", filename, i, len(data))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := Marshal(record)
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if !bytes.Equal(b, data[i]) {
+				t.Errorf("%s: record %d => %q, want: %q", filename, i, b, data[i])
+			}
+		}
+	}
+}
+
+func TestMIJReader(t *testing.T) {
+	var record = `{"leader": "00000nam a2200000 a 4500", "fields": [
+		{"001": "1"}, {"245": {"ind1": "1", "ind2": "0", "subfields": [{"a": "Title"}]}}]}`
+	var invalid = `{"leader": "00000nam", "fields": []}`
+
+	var tests = []struct {
+		in    string
+		count int
+		errs  []error
+	}{
+		{"", 0, nil},
+		{record, 1, nil},
+		{record + "\n" + record + "\n", 2, nil},
+		{" [" + record + ", " + record + "]", 2, nil},
+		{"[]", 0, nil},
+		{record + "\n" + invalid + "\n" + record, 2, []error{ErrInvalidLeader}},
+		{`{"leader": "00000nam a2200000 a 4500", "fields": [{"001": "1", "002": "2"}]}`, 0, []error{ErrInvalidRecord}},
+		{record + "\n{", 1, []error{ErrInvalidRecord}},
+	}
+
+	for _, tt := range tests {
+		reader := NewMIJReader(strings.NewReader(tt.in))
+		var count int
+		var errs []error
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				errs = append(errs, err)
+				var rerr *RecordError
+				if !errors.As(err, &rerr) {
+					break
+				}
+				continue
+			}
+			if record.GetControlFields("001")[0].Data != "1" {
+				t.Errorf("NewMIJReader(%q): unexpected record: %v", tt.in, record)
+			}
+			count++
+		}
+		if count != tt.count {
+			t.Errorf("NewMIJReader(%q) => %d records, want: %d", tt.in, count, tt.count)
+		}
+		if len(errs) != len(tt.errs) {
+			t.Errorf("NewMIJReader(%q) => errors %v, want: %v", tt.in, errs, tt.errs)
+			continue
+		}
+		for i, err := range errs {
+			if !errors.Is(err, tt.errs[i]) {
+				t.Errorf("NewMIJReader(%q) => %v, want: %v", tt.in, err, tt.errs[i])
+			}
+		}
+	}
+}
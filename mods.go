@@ -0,0 +1,392 @@
+package marctools
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// MODSNamespace is the namespace of MODS version 3
+const MODSNamespace = "http://www.loc.gov/mods/v3"
+
+// MODSVersion is the version of MODS written by MarshalMODS
+const MODSVersion = "3.7"
+
+// modsSchema is the location of the schema of MODSVersion
+const modsSchema = MODSNamespace + " http://www.loc.gov/standards/mods/v3/mods-3-7.xsd"
+
+// Start and end of a MODS collection, records go in between.
+const (
+	MODSCollectionStart = xml.Header + `<modsCollection xmlns="` + MODSNamespace + `" xmlns:xsi="` + xsiNamespace +
+		`" xsi:schemaLocation="` + modsSchema + `">` + "\n"
+	MODSCollectionEnd = "</modsCollection>\n"
+)
+
+// modsTypes maps leader/06 to typeOfResource
+var modsTypes = map[byte]string{
+	'a': "text",
+	't': "text",
+	'e': "cartographic",
+	'f': "cartographic",
+	'c': "notated music",
+	'd': "notated music",
+	'i': "sound recording-nonmusical",
+	'j': "sound recording-musical",
+	'k': "still image",
+	'g': "moving image",
+	'r': "three dimensional object",
+	'm': "software, multimedia",
+	'o': "mixed material",
+	'p': "mixed material",
+}
+
+// modsIssuance maps leader/07 to the issuance
+var modsIssuance = map[byte]string{
+	'a': "monographic",
+	'c': "monographic",
+	'd': "monographic",
+	'm': "monographic",
+	'b': "continuing",
+	'i': "integrating resource",
+	's': "serial",
+}
+
+// modsEvents maps the second indicator of 264 to the eventType
+var modsEvents = map[string]string{
+	"0": "production",
+	"1": "publication",
+	"2": "distribution",
+	"3": "manufacture",
+}
+
+// modsAuthorities maps the second indicator of subject fields to the
+// authority, 7 means the source is given in $2
+var modsAuthorities = map[string]string{
+	"0": "lcsh",
+	"1": "lcshac",
+	"2": "mesh",
+	"3": "nal",
+	"5": "csh",
+	"6": "rvm",
+}
+
+// modsRelatedTypes maps linking entries to the type of the related item
+var modsRelatedTypes = map[string]string{
+	"760": "series",
+	"762": "constituent",
+	"765": "original",
+	"767": "otherVersion",
+	"773": "host",
+	"775": "otherVersion",
+	"776": "otherFormat",
+	"780": "preceding",
+	"785": "succeeding",
+	"786": "original",
+}
+
+// modsSubdivisions maps subject subdivisions to elements
+var modsSubdivisions = map[string]string{
+	"v": "genre",
+	"x": "topic",
+	"y": "temporal",
+	"z": "geographic",
+}
+
+// modsName adds a name element for a X00, X10 or X11 field
+func modsName(parent *element, field marc22.DataField) *element {
+	var name *element
+	switch field.Tag[1:] {
+	case "00":
+		name = parent.add("name", "", "type", "personal")
+		name.add("namePart", chopPunctuation(subfieldsText(field, "aq")))
+		name.add("namePart", chopPunctuation(subfieldsText(field, "bc")), "type", "termsOfAddress")
+		name.add("namePart", chopPunctuation(firstSubfield(field, "d")), "type", "date")
+	case "10":
+		name = parent.add("name", "", "type", "corporate")
+		name.add("namePart", chopPunctuation(firstSubfield(field, "a")))
+		for _, v := range subfieldValues(field, "b") {
+			name.add("namePart", chopPunctuation(v))
+		}
+	case "11":
+		name = parent.add("name", "", "type", "conference")
+		name.add("namePart", chopPunctuation(subfieldsText(field, "acdnq")))
+	default:
+		name = parent.add("name", "")
+		name.add("namePart", chopPunctuation(firstSubfield(field, "a")))
+	}
+	for _, v := range subfieldValues(field, "e") {
+		name.add("role", "").add("roleTerm", chopPunctuation(v), "type", "text", "authority", "marcrelator")
+	}
+	for _, v := range subfieldValues(field, "4") {
+		name.add("role", "").add("roleTerm", v, "type", "code", "authority", "marcrelator")
+	}
+	return name
+}
+
+// modsTitle adds a titleInfo element for 245, 246, 130 or 240
+func modsTitle(parent *element, field marc22.DataField) {
+	var info *element
+	switch field.Tag {
+	case "245":
+		info = parent.add("titleInfo", "")
+		title := subfieldsText(field, "afgk")
+		// the second indicator gives the number of nonfiling characters
+		if len(field.Ind2) == 1 && field.Ind2 > "0" && field.Ind2 <= "9" {
+			// count characters, not bytes, e.g. for "L’"
+			if r, n := []rune(title), int(field.Ind2[0]-'0'); n < len(r) {
+				info.add("nonSort", string(r[:n]))
+				title = string(r[n:])
+			}
+		}
+		info.add("title", chopPunctuation(title))
+	case "246":
+		info = parent.add("titleInfo", "", "type", "alternative", "displayLabel", firstSubfield(field, "i"))
+		info.add("title", chopPunctuation(firstSubfield(field, "a")))
+	default:
+		info = parent.add("titleInfo", "", "type", "uniform")
+		info.add("title", chopPunctuation(subfieldsText(field, "adfklmors")))
+	}
+	info.add("subTitle", chopPunctuation(firstSubfield(field, "b")))
+	for _, v := range subfieldValues(field, "n") {
+		info.add("partNumber", chopPunctuation(v))
+	}
+	for _, v := range subfieldValues(field, "p") {
+		info.add("partName", chopPunctuation(v))
+	}
+}
+
+// modsOriginInfo adds the place, publisher and date of 260 or 264 to an
+// originInfo element
+func modsOriginInfo(info *element, field marc22.DataField) {
+	for _, v := range subfieldValues(field, "a") {
+		info.add("place", "").add("placeTerm", chopPunctuation(v), "type", "text")
+	}
+	for _, v := range subfieldValues(field, "b") {
+		info.add("publisher", chopPunctuation(v))
+	}
+	date := "dateIssued"
+	if field.Tag == "264" && field.Ind2 == "4" {
+		date = "copyrightDate"
+	}
+	for _, v := range subfieldValues(field, "c") {
+		info.add(date, chopPunctuation(v))
+	}
+}
+
+// modsSubject adds a subject element for a 6XX field
+func modsSubject(parent *element, field marc22.DataField) {
+	authority := modsAuthorities[field.Ind2]
+	if field.Ind2 == "7" {
+		authority = firstSubfield(field, "2")
+	}
+	subject := parent.add("subject", "", "authority", authority)
+	switch field.Tag {
+	case "600", "610", "611":
+		modsName(subject, field)
+	case "630":
+		subject.add("titleInfo", "").add("title", chopPunctuation(subfieldsText(field, "adfklmors")))
+	case "648":
+		subject.add("temporal", chopPunctuation(firstSubfield(field, "a")))
+	case "650":
+		subject.add("topic", chopPunctuation(subfieldsText(field, "abcd")))
+	case "651":
+		subject.add("geographic", chopPunctuation(firstSubfield(field, "a")))
+	case "653":
+		for _, v := range subfieldValues(field, "a") {
+			subject.add("topic", chopPunctuation(v))
+		}
+	}
+	for _, subfield := range field.SubFields {
+		if name, ok := modsSubdivisions[subfield.Code]; ok {
+			subject.add(name, chopPunctuation(subfield.Value))
+		}
+	}
+}
+
+// modsRelatedItem adds a relatedItem element for a linking entry, 76X-78X
+func modsRelatedItem(parent *element, field marc22.DataField) {
+	item := parent.add("relatedItem", "", "type", modsRelatedTypes[field.Tag])
+	item.add("titleInfo", "").add("title", chopPunctuation(firstSubfield(field, "t")))
+	item.add("name", "").add("namePart", chopPunctuation(firstSubfield(field, "a")))
+	for _, v := range subfieldValues(field, "x") {
+		item.add("identifier", v, "type", "issn")
+	}
+	for _, v := range subfieldValues(field, "z") {
+		item.add("identifier", v, "type", "isbn")
+	}
+	for _, v := range subfieldValues(field, "w") {
+		item.add("identifier", v, "type", "local")
+	}
+}
+
+// MarshalMODS serializes a record to a MODS 3.7 mods element, following the
+// LoC MARC to MODS mapping for titles, names, origin (260, 264), physical
+// description (300), notes, subjects, classification, related items, standard
+// identifiers and locations (856). The element declares the MODS namespace.
+func MarshalMODS(record *marc22.Record, indent bool) ([]byte, error) {
+	if _, err := leaderBytes(record); err != nil {
+		return nil, err
+	}
+	mods := &element{name: "mods", attrs: []string{
+		"xmlns", MODSNamespace,
+		"xmlns:xsi", xsiNamespace,
+		"version", MODSVersion,
+		"xsi:schemaLocation", modsSchema,
+	}}
+	// elements are created upfront to keep the order of the stylesheet
+	var (
+		titles   = &element{}
+		names    = &element{}
+		genres   = &element{}
+		origins  = &element{}
+		physical = &element{name: "physicalDescription"}
+		notes    = &element{}
+		subjects = &element{}
+		classes  = &element{}
+		related  = &element{}
+		ids      = &element{}
+		location = &element{}
+		access   = &element{}
+	)
+	leader6, leader7 := leaderByte(record, 6), leaderByte(record, 7)
+	origin := origins.add("originInfo", "")
+	origin.add("place", "").add("placeTerm", fixedField(record, "008", 15, 18), "type", "code", "authority", "marccountry")
+	languages := []string{fixedField(record, "008", 35, 38)}
+
+	for _, field := range record.DataFields {
+		switch tag := field.Tag; {
+		case isTag(tag, "130", "240", "245", "246"):
+			modsTitle(titles, field)
+		case isTag(tag, "100", "110", "111", "700", "710", "711", "720"):
+			name := modsName(names, field)
+			if tag[0] == '1' {
+				name.attrs = append(name.attrs, "usage", "primary")
+			}
+		case tag == "655":
+			genres.add("genre", chopPunctuation(subfieldsText(field, "abvxyz")), "authority", firstSubfield(field, "2"))
+		case tag == "260":
+			modsOriginInfo(origin, field)
+		case tag == "264":
+			modsOriginInfo(origins.add("originInfo", "", "eventType", modsEvents[field.Ind2]), field)
+		case tag == "250":
+			origin.add("edition", chopPunctuation(firstSubfield(field, "a")))
+		case tag == "310":
+			origin.add("frequency", chopPunctuation(firstSubfield(field, "a")))
+		case tag == "041":
+			languages = append(languages, subfieldValues(field, "a")...)
+		case tag == "300":
+			physical.add("extent", subfieldsText(field, "abcefg"))
+		case tag == "520":
+			notes.add("abstract", subfieldsText(field, "ab"))
+		case tag == "505":
+			notes.add("tableOfContents", subfieldsText(field, "agrt"))
+		case tag == "506":
+			access.add("accessCondition", subfieldsText(field, "abcd"), "type", "restriction on access")
+		case tag == "540":
+			access.add("accessCondition", subfieldsText(field, "abcd"), "type", "use and reproduction")
+		case isNote(tag):
+			notes.add("note", subfieldsText(field, alphabetic))
+		case isTag(tag, "600", "610", "611", "630", "648", "650", "651", "653"):
+			modsSubject(subjects, field)
+		case tag == "050":
+			classes.add("classification", subfieldsText(field, "ab"), "authority", "lcc")
+		case tag == "060":
+			classes.add("classification", subfieldsText(field, "ab"), "authority", "nlm")
+		case tag == "080":
+			classes.add("classification", subfieldsText(field, "ab"), "authority", "udc")
+		case tag == "082":
+			for _, v := range subfieldValues(field, "a") {
+				classes.add("classification", v, "authority", "ddc", "edition", firstSubfield(field, "2"))
+			}
+		case tag == "490" || tag == "830":
+			item := related.add("relatedItem", "", "type", "series")
+			info := item.add("titleInfo", "")
+			info.add("title", chopPunctuation(firstSubfield(field, "a")))
+			info.add("partNumber", chopPunctuation(firstSubfield(field, "v")))
+		case isRelatedEntry(tag):
+			modsRelatedItem(related, field)
+		case tag == "010":
+			ids.add("identifier", firstSubfield(field, "a"), "type", "lccn")
+		case tag == "020" || tag == "022":
+			kind := map[string]string{"020": "isbn", "022": "issn"}[tag]
+			for _, v := range subfieldValues(field, "a") {
+				ids.add("identifier", v, "type", kind)
+			}
+			for _, v := range subfieldValues(field, "z") {
+				ids.add("identifier", v, "type", kind, "invalid", "yes")
+			}
+		case tag == "856":
+			label := firstSubfield(field, "y")
+			if label == "" {
+				label = firstSubfield(field, "3")
+			}
+			loc := location.add("location", "")
+			for _, v := range subfieldValues(field, "u") {
+				loc.add("url", v, "displayLabel", label, "note", firstSubfield(field, "z"))
+			}
+			for _, v := range subfieldValues(field, "q") {
+				physical.add("internetMediaType", v)
+			}
+		}
+	}
+
+	// dates and issuance from 008 and the leader
+	if date := fixedField(record, "008", 7, 11); date != "" {
+		// a second date is the end of a range for these types of date
+		var end string
+		if t := fixedField(record, "008", 6, 7); t != "" && strings.Contains("cdikmqu", t) {
+			end = fixedField(record, "008", 11, 15)
+		}
+		if end == "" {
+			origin.add("dateIssued", date, "encoding", "marc")
+		} else {
+			origin.add("dateIssued", date, "encoding", "marc", "point", "start")
+			origin.add("dateIssued", end, "encoding", "marc", "point", "end")
+		}
+	}
+	origin.add("issuance", modsIssuance[leader7])
+
+	mods.children = append(mods.children, titles.children...)
+	mods.children = append(mods.children, names.children...)
+	var collection, manuscript string
+	if leader7 == 'c' {
+		collection = "yes"
+	}
+	switch leader6 {
+	case 'd', 'f', 'p', 't':
+		manuscript = "yes"
+	}
+	mods.add("typeOfResource", modsTypes[leader6], "collection", collection, "manuscript", manuscript)
+	mods.children = append(mods.children, genres.children...)
+	mods.children = append(mods.children, origins.children...)
+	seen := make(map[string]bool)
+	for _, code := range languages {
+		if code != "" && !seen[code] {
+			mods.add("language", "").add("languageTerm", code, "authority", "iso639-2b", "type", "code")
+			seen[code] = true
+		}
+	}
+	mods.children = append(mods.children, physical)
+	mods.children = append(mods.children, notes.children...)
+	mods.children = append(mods.children, subjects.children...)
+	mods.children = append(mods.children, classes.children...)
+	mods.children = append(mods.children, related.children...)
+	mods.children = append(mods.children, ids.children...)
+	mods.children = append(mods.children, location.children...)
+	mods.children = append(mods.children, access.children...)
+
+	info := mods.add("recordInfo", "")
+	for _, field := range record.DataFields {
+		if field.Tag == "040" {
+			info.add("recordContentSource", firstSubfield(field, "a"), "authority", "marcorg")
+			info.add("languageOfCataloging", "").add("languageTerm", firstSubfield(field, "b"), "authority", "iso639-2b", "type", "code")
+			break
+		}
+	}
+	info.add("recordCreationDate", fixedField(record, "008", 0, 6), "encoding", "marc")
+	info.add("recordChangeDate", strings.TrimSpace(controlFieldValue(record, "005")), "encoding", "iso8601")
+	info.add("recordIdentifier", strings.TrimSpace(controlFieldValue(record, "001")), "source", strings.TrimSpace(controlFieldValue(record, "003")))
+	info.add("recordOrigin", "Converted from MARC 21 to MODS version "+MODSVersion+" by marctools")
+	return mods.bytes(indent), nil
+}
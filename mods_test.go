@@ -0,0 +1,117 @@
+package marctools
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+func TestMarshalMODS(t *testing.T) {
+	b, err := MarshalMODS(crosswalkTestRecord, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mods struct {
+		XMLName   xml.Name
+		Version   string `xml:"version,attr"`
+		TitleInfo []struct {
+			NonSort  string `xml:"nonSort"`
+			Title    string `xml:"title"`
+			SubTitle string `xml:"subTitle"`
+		} `xml:"titleInfo"`
+		Name []struct {
+			Type     string   `xml:"type,attr"`
+			Usage    string   `xml:"usage,attr"`
+			NamePart []string `xml:"namePart"`
+			Role     []string `xml:"role>roleTerm"`
+		} `xml:"name"`
+		TypeOfResource string `xml:"typeOfResource"`
+		OriginInfo     []struct {
+			EventType  string   `xml:"eventType,attr"`
+			Place      []string `xml:"place>placeTerm"`
+			Publisher  string   `xml:"publisher"`
+			DateIssued []string `xml:"dateIssued"`
+			Issuance   string   `xml:"issuance"`
+		} `xml:"originInfo"`
+		Language []string `xml:"language>languageTerm"`
+		Extent   string   `xml:"physicalDescription>extent"`
+		Note     []string `xml:"note"`
+		Subject  []struct {
+			Authority  string   `xml:"authority,attr"`
+			Topic      []string `xml:"topic"`
+			Geographic []string `xml:"geographic"`
+			Genre      []string `xml:"genre"`
+		} `xml:"subject"`
+		Identifier []string `xml:"identifier"`
+		URL        []string `xml:"location>url"`
+		RecordID   string   `xml:"recordInfo>recordIdentifier"`
+	}
+	if err := xml.Unmarshal(b, &mods); err != nil {
+		t.Fatalf("MarshalMODS: %s: %s", err, b)
+	}
+	if mods.XMLName.Space != MODSNamespace || mods.XMLName.Local != "mods" || mods.Version != MODSVersion {
+		t.Errorf("MarshalMODS: got root element %v, version %s", mods.XMLName, mods.Version)
+	}
+
+	var tests = []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"nonSort", mods.TitleInfo[0].NonSort, "Die "},
+		{"title", mods.TitleInfo[0].Title, "Leiden des jungen Werthers"},
+		{"subTitle", mods.TitleInfo[0].SubTitle, "Roman"},
+		{"name type", mods.Name[0].Type + " " + mods.Name[0].Usage, "personal primary"},
+		{"namePart", mods.Name[0].NamePart, []string{"Goethe, Johann Wolfgang von", "1749-1832"}},
+		{"roleTerm", mods.Name[0].Role, []string{"author"}},
+		{"typeOfResource", mods.TypeOfResource, "text"},
+		{"originInfo", len(mods.OriginInfo), 2},
+		{"marc dates", mods.OriginInfo[0].DateIssued, []string{"2015"}},
+		{"issuance", mods.OriginInfo[0].Issuance, "monographic"},
+		{"country", mods.OriginInfo[0].Place, []string{"gw"}},
+		{"eventType", mods.OriginInfo[1].EventType, "publication"},
+		{"place", mods.OriginInfo[1].Place, []string{"Leipzig"}},
+		{"publisher", mods.OriginInfo[1].Publisher, "Weygand"},
+		{"dateIssued", mods.OriginInfo[1].DateIssued, []string{"2015"}},
+		{"language", mods.Language, []string{"ger"}},
+		{"extent", mods.Extent, "224 S. ; 19 cm"},
+		{"note", mods.Note, []string{"Briefroman."}},
+		{"subjects", len(mods.Subject), 2},
+		{"subject authority", mods.Subject[0].Authority, "lcsh"},
+		{"topic", mods.Subject[0].Topic, []string{"Suicide"}},
+		{"genre", mods.Subject[0].Genre, []string{"Fiction"}},
+		{"geographic", mods.Subject[1].Geographic, []string{"Germany"}},
+		{"identifier", mods.Identifier, []string{"9783161484100"}},
+		{"url", mods.URL, []string{"http://example.org/werther?a=1&b=2"}},
+		{"recordIdentifier", mods.RecordID, "123"},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("MarshalMODS: %s => %v, want: %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestMarshalMODSNonSortCharacters(t *testing.T) {
+	record := &marc22.Record{
+		Leader: "00000nam a2200000 a 4500",
+		DataFields: []marc22.DataField{
+			{Tag: "245", Ind1: "1", Ind2: "2", SubFields: []*marc22.SubField{{Code: "a", Value: "L’été meurtrier"}}},
+		},
+	}
+	b, err := MarshalMODS(record, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mods struct {
+		NonSort string `xml:"titleInfo>nonSort"`
+		Title   string `xml:"titleInfo>title"`
+	}
+	if err := xml.Unmarshal(b, &mods); err != nil {
+		t.Fatalf("MarshalMODS: %s: %s", err, b)
+	}
+	if mods.NonSort != "L’" || mods.Title != "été meurtrier" {
+		t.Errorf("MarshalMODS: got nonSort %q, title %q, want: %q, %q", mods.NonSort, mods.Title, "L’", "été meurtrier")
+	}
+}
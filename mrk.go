@@ -0,0 +1,296 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// MRK mnemonics for characters, that cannot be written literally
+var mrkMnemonics = map[string]byte{
+	"dollar": '$',
+	"lcub":   '{',
+	"rcub":   '}',
+	"bsol":   '\\',
+	"esc":    0x1b,
+}
+
+// mrkEscape writes a value in MRK notation. Special characters are written
+// as mnemonics, e.g. {dollar}, other control characters as {U+XXXX}. If
+// blank is true, spaces are written as backslashes, as in the leader,
+// control fields and indicators.
+func mrkEscape(buf *bytes.Buffer, s string, blank bool) {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '$':
+			buf.WriteString("{dollar}")
+		case c == '{':
+			buf.WriteString("{lcub}")
+		case c == '}':
+			buf.WriteString("{rcub}")
+		case c == '\\':
+			buf.WriteString("{bsol}")
+		case c == 0x1b:
+			buf.WriteString("{esc}")
+		case c == ' ' && blank:
+			buf.WriteByte('\\')
+		case c < 0x20 || c == 0x7f:
+			fmt.Fprintf(buf, "{U+%04X}", c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// mrkUnescape reverses mrkEscape
+func mrkUnescape(s string, blank bool) (string, error) {
+	if !strings.ContainsAny(s, "{\\") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && blank:
+			sb.WriteByte(' ')
+		case c == '{':
+			j := strings.IndexByte(s[i:], '}')
+			if j < 0 {
+				return "", fmt.Errorf("unterminated mnemonic: %q", s[i:])
+			}
+			name := s[i+1 : i+j]
+			if b, ok := mrkMnemonics[name]; ok {
+				sb.WriteByte(b)
+			} else if strings.HasPrefix(name, "U+") {
+				r, err := strconv.ParseUint(name[2:], 16, 32)
+				if err != nil {
+					return "", fmt.Errorf("invalid mnemonic: {%s}", name)
+				}
+				sb.WriteRune(rune(r))
+			} else {
+				return "", fmt.Errorf("unknown mnemonic: {%s}", name)
+			}
+			i += j
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+// MarshalMRK serializes a record into the MARCMaker (MRK) text format, as
+// used by MarcEdit: one line per field, starting with =LDR for the leader.
+// Values are written as is, so the record should be UTF-8 encoded.
+func MarshalMRK(record *marc22.Record) ([]byte, error) {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("=LDR  ")
+	mrkEscape(&buf, string(leader), true)
+	buf.WriteByte('\n')
+
+	for _, field := range record.ControlFields {
+		buf.WriteString("=" + field.Tag + "  ")
+		mrkEscape(&buf, field.Data, true)
+		buf.WriteByte('\n')
+	}
+	for _, field := range record.DataFields {
+		ind1, err := indicator(field.Ind1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		ind2, err := indicator(field.Ind2)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		buf.WriteString("=" + field.Tag + "  ")
+		mrkEscape(&buf, string([]byte{ind1, ind2}), true)
+		for _, subfield := range field.SubFields {
+			if subfield.Code != "" {
+				buf.WriteString("$" + subfield.Code)
+			}
+			mrkEscape(&buf, subfield.Value, false)
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMRK parses a single record in MRK format. Lines must start with
+// =TAG, followed by two spaces; the leader (=LDR) is required.
+func UnmarshalMRK(b []byte) (*marc22.Record, error) {
+	record := &marc22.Record{}
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\r\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) < 4 || line[0] != '=' {
+			return nil, fmt.Errorf("%w: invalid line: %q", ErrInvalidRecord, line)
+		}
+		tag, data := line[1:4], strings.TrimPrefix(line[4:], "  ")
+		switch {
+		case tag == "LDR" || tag == "000":
+			leader, err := mrkUnescape(data, true)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidLeader, err)
+			}
+			if record.LeaderParsed, err = parseLeaderTemplate(leader); err != nil {
+				return nil, err
+			}
+			record.Leader = leader
+		case strings.HasPrefix(tag, "00"):
+			value, err := mrkUnescape(data, true)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, tag, err)
+			}
+			record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: tag, Data: value})
+		default:
+			field, err := unmarshalMRKDataField(tag, data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %s", ErrInvalidRecord, tag, err)
+			}
+			record.DataFields = append(record.DataFields, field)
+		}
+	}
+	if record.LeaderParsed == nil {
+		return nil, fmt.Errorf("%w: record has no leader", ErrInvalidLeader)
+	}
+	return record, nil
+}
+
+// unmarshalMRKDataField parses indicators and subfields of a data field
+func unmarshalMRKDataField(tag, data string) (marc22.DataField, error) {
+	field := marc22.DataField{Tag: tag}
+	// indicators may be escaped, e.g. {dollar}, so unescape them one at a
+	// time before looking for subfield delimiters
+	var indicators [2]string
+	for k := range indicators {
+		n := 1
+		if strings.HasPrefix(data, "{") {
+			n = strings.IndexByte(data, '}') + 1
+		}
+		if n < 1 || len(data) < n {
+			return field, fmt.Errorf("missing indicators: %q", data)
+		}
+		value, err := mrkUnescape(data[:n], true)
+		if err != nil {
+			return field, err
+		}
+		if len(value) != 1 {
+			return field, fmt.Errorf("invalid indicator: %q", data[:n])
+		}
+		indicators[k], data = value, data[n:]
+	}
+	field.Ind1, field.Ind2 = indicators[0], indicators[1]
+	var err error
+	for i, chunk := range strings.Split(data, "$") {
+		var subfield marc22.SubField
+		switch {
+		case i == 0 && chunk == "":
+			continue
+		case i == 0:
+			// data before the first delimiter, cf. ParseRecord
+			subfield.Value = chunk
+		case chunk == "":
+			return field, fmt.Errorf("missing subfield code")
+		default:
+			subfield.Code, subfield.Value = chunk[0:1], chunk[1:]
+		}
+		if subfield.Value, err = mrkUnescape(subfield.Value, false); err != nil {
+			return field, err
+		}
+		field.SubFields = append(field.SubFields, &subfield)
+	}
+	return field, nil
+}
+
+// MRKReader reads records in MRK format from a stream. Records are
+// separated by blank lines, or start with a leader line.
+type MRKReader struct {
+	// Filename is used in errors, if not empty.
+	Filename string
+
+	r     *bufio.Reader
+	line  int    // number of lines read
+	next  string // leader line of the next record, if already read
+	index int64
+	err   error
+}
+
+// NewMRKReader returns a reader, that reads from r.
+func NewMRKReader(r io.Reader) *MRKReader {
+	return &MRKReader{r: bufio.NewReader(r)}
+}
+
+// readLine returns the next line, without line terminator
+func (r *MRKReader) readLine() (string, error) {
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	r.line++
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// isLeaderLine reports whether a line starts a record
+func isLeaderLine(line string) bool {
+	return strings.HasPrefix(line, "=LDR") || strings.HasPrefix(line, "=000")
+}
+
+// Next returns the next record or io.EOF. Invalid records are reported as
+// *RecordError and reading can continue.
+func (r *MRKReader) Next() (*marc22.Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var lines []string
+	var start int
+	if r.next != "" {
+		lines, start, r.next = append(lines, r.next), r.line, ""
+	}
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			if err != io.EOF {
+				r.err = err
+				return nil, err
+			}
+			if len(lines) == 0 {
+				r.err = io.EOF
+				return nil, r.err
+			}
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(lines) == 0 {
+				continue
+			}
+			break
+		}
+		if isLeaderLine(line) && len(lines) > 0 {
+			r.next = line
+			break
+		}
+		if len(lines) == 0 {
+			start = r.line
+		}
+		lines = append(lines, line)
+	}
+	index := r.index
+	r.index++
+	record, err := UnmarshalMRK([]byte(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Line: start, Err: err}
+	}
+	return record, nil
+}
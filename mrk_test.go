@@ -0,0 +1,157 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+func TestMRKRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		var data [][]byte
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := MarshalMRK(record)
+			if err != nil {
+				t.Fatalf("MarshalMRK(%s, %d) => %s", filename, rr.Index, err)
+			}
+			buf.Write(b)
+			buf.WriteString("\n")
+			data = append(data, rr.Data)
+		}
+		file.Close()
+
+		mr := NewMRKReader(&buf)
+		for i := 0; ; i++ {
+			record, err := mr.Next()
+			if err == io.EOF {
+				if i != len(data) {
+					t.Errorf("%s: got %d records, want: %d", filename, i, len(data))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := Marshal(record)
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if !bytes.Equal(b, data[i]) {
+				t.Errorf("%s: record %d => %q, want: %q", filename, i, b, data[i])
+			}
+		}
+	}
+
+	// indicators that need escaping must not be taken for delimiters
+	record := &marc22.Record{
+		Leader: "00000nam a2200000 a 4500",
+		DataFields: []marc22.DataField{
+			{Tag: "245", Ind1: "$", Ind2: "{", SubFields: []*marc22.SubField{{Code: "a", Value: "Title"}}},
+			{Tag: "246", Ind1: "\\", Ind2: " ", SubFields: []*marc22.SubField{{Code: "a", Value: "Other"}}},
+		},
+	}
+	b, err := MarshalMRK(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := UnmarshalMRK(b)
+	if err != nil {
+		t.Fatalf("UnmarshalMRK(%q) => %s", b, err)
+	}
+	parsed.LeaderParsed = nil
+	if !reflect.DeepEqual(parsed, record) {
+		t.Errorf("UnmarshalMRK(%q) => %+v, want: %+v", b, parsed, record)
+	}
+}
+
+func TestMarshalMRK(t *testing.T) {
+	record := &marc22.Record{
+		Leader:        "00000nam a2200000 a 4500",
+		ControlFields: []marc22.ControlField{{Tag: "008", Data: `a b\c`}},
+		DataFields: []marc22.DataField{{Tag: "245", Ind1: "1", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "US$ 5 {approx.} \\ \x1b(3"},
+			{Code: "c", Value: "a\x1fb"},
+		}}},
+	}
+	want := "=LDR  00000nam\\a2200000\\a\\4500\n" +
+		"=008  a\\b{bsol}c\n" +
+		"=245  1\\$aUS{dollar} 5 {lcub}approx.{rcub} {bsol} {esc}(3$ca{U+001F}b\n"
+	b, err := MarshalMRK(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != want {
+		t.Errorf("MarshalMRK => %q, want: %q", b, want)
+	}
+	parsed, err := UnmarshalMRK(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.LeaderParsed = nil
+	record.DataFields[0].Ind2 = " "
+	if !reflect.DeepEqual(parsed, record) {
+		t.Errorf("UnmarshalMRK(%q) => %+v, want: %+v", b, parsed, record)
+	}
+}
+
+func TestMRKReader(t *testing.T) {
+	var in = "\r\n=LDR  00000nam\\a2200000\\a\\4500\r\n=001  1\r\n=245  10$aTitle\r\n\r\n\r\n" +
+		"=LDR  00000nam\\a2200000\\a\\4500\n=001  2\n=245  10$aTitle{unknown}\n" +
+		"=LDR  00000nam\\a2200000\\a\\4500\n=001  3\n\n" +
+		"=001  4\n\n" +
+		"=LDR  00000nam\\a2200000\\a\\4500\n=001  5\n245  10$aTitle\n\n" +
+		"=LDR  00000nam\\a2200000\\a\\4500\n=001  6"
+
+	var ids []string
+	var lines []int
+	reader := NewMRKReader(strings.NewReader(in))
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var rerr *RecordError
+			if !errors.As(err, &rerr) {
+				t.Fatal(err)
+			}
+			lines = append(lines, rerr.Line)
+			continue
+		}
+		ids = append(ids, record.ControlFields[0].Data)
+	}
+	if want := []string{"1", "3", "6"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("MRKReader: got ids %v, want: %v", ids, want)
+	}
+	if want := []int{7, 13, 15}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("MRKReader: got errors at lines %v, want: %v", lines, want)
+	}
+}
@@ -0,0 +1,67 @@
+package marctools
+
+import "github.com/miku/marc22"
+
+// Batch is a batch of records with a sequence number, cf. Sequencer.
+type Batch struct {
+	Seq     int64
+	Records []*marc22.Record
+	Origins []*RecordOrigin // origins of the records, if needed
+}
+
+// BatchResult holds the serialized records of a batch. Workers must send a
+// result for every batch, even if it has no items.
+type BatchResult struct {
+	Seq   int64
+	Items [][]byte
+}
+
+// Sequencer numbers batches, which are processed by parallel workers, and
+// restores their original order afterwards, so the output does not depend
+// on the scheduling of the workers. At most window batches are in flight,
+// which bounds the memory needed to hold results, that arrive early.
+type Sequencer struct {
+	slots chan struct{}
+	seq   int64
+}
+
+// NewSequencer returns a sequencer, that allows window batches in flight.
+// Twice the number of workers keeps all workers busy.
+func NewSequencer(window int) *Sequencer {
+	if window < 1 {
+		window = 1
+	}
+	return &Sequencer{slots: make(chan struct{}, window)}
+}
+
+// Next blocks until another batch may be sent and returns its sequence
+// number. Sequence numbers start at 0.
+func (s *Sequencer) Next() int64 {
+	s.slots <- struct{}{}
+	seq := s.seq
+	s.seq++
+	return seq
+}
+
+// Reorder sends the items of the results on out, in the order of the batch
+// sequence numbers, and closes out once in is closed.
+func (s *Sequencer) Reorder(in chan BatchResult, out chan []byte) {
+	pending := make(map[int64][][]byte)
+	var next int64
+	for result := range in {
+		pending[result.Seq] = result.Items
+		for {
+			items, ok := pending[next]
+			if !ok {
+				break
+			}
+			for _, b := range items {
+				out <- b
+			}
+			delete(pending, next)
+			next++
+			<-s.slots
+		}
+	}
+	close(out)
+}
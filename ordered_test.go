@@ -0,0 +1,94 @@
+package marctools
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSequencer(t *testing.T) {
+	var tests = []struct {
+		batches int
+		workers int
+	}{
+		{0, 1},
+		{1, 1},
+		{10, 1},
+		{100, 4},
+		{1000, 16},
+	}
+	for _, tt := range tests {
+		seq := NewSequencer(2 * tt.workers)
+		queue := make(chan Batch)
+		results := make(chan BatchResult)
+		out := make(chan []byte)
+
+		go seq.Reorder(results, out)
+
+		var wg sync.WaitGroup
+		for i := 0; i < tt.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := range queue {
+					// even batches carry two items, odd batches none
+					result := BatchResult{Seq: batch.Seq}
+					if batch.Seq%2 == 0 {
+						result.Items = [][]byte{
+							[]byte(fmt.Sprintf("%d", batch.Seq)),
+							[]byte(fmt.Sprintf("%d'", batch.Seq)),
+						}
+					}
+					results <- result
+				}
+			}()
+		}
+		go func() {
+			for i := 0; i < tt.batches; i++ {
+				queue <- Batch{Seq: seq.Next()}
+			}
+			close(queue)
+			wg.Wait()
+			close(results)
+		}()
+
+		var got []string
+		for b := range out {
+			got = append(got, string(b))
+		}
+		var want []string
+		for i := 0; i < tt.batches; i += 2 {
+			want = append(want, fmt.Sprintf("%d", i), fmt.Sprintf("%d'", i))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Reorder with %d batches, %d workers => %v, want: %v", tt.batches, tt.workers, got, want)
+		}
+	}
+}
+
+func TestSequencerReorder(t *testing.T) {
+	// results arrive in reverse order, the window is large enough to hold them
+	seq := NewSequencer(5)
+	for i := 0; i < 5; i++ {
+		seq.Next()
+	}
+	results := make(chan BatchResult, 5)
+	for i := 4; i >= 0; i-- {
+		results <- BatchResult{Seq: int64(i), Items: [][]byte{[]byte(fmt.Sprintf("%d", i))}}
+	}
+	close(results)
+	out := make(chan []byte, 5)
+	seq.Reorder(results, out)
+
+	var got []string
+	for b := range out {
+		got = append(got, string(b))
+	}
+	if want := "[0 1 2 3 4]"; fmt.Sprint(got) != want {
+		t.Errorf("Reorder => %v, want: %v", got, want)
+	}
+	// all slots are free again
+	if n := len(seq.slots); n != 0 {
+		t.Errorf("slots in use => %d, want: 0", n)
+	}
+}
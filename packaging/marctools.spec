@@ -14,17 +14,24 @@ Highlights:
 
 * marctojson -- convert MARC to JSON
 * marctotsv  -- convert MARC to TAB-separated file
+* marctoxml  -- convert MARC to MARCXML, Dublin Core or MODS
 
 Other:
 
+* marctools  -- all commands as subcommands of a single binary
+* jsontomarc
 * marccount
 * marcdb
 * marcdump
 * marcmap
 * marcsnapshot
 * marcsplit
+* marctosql
 * marcuniq
 * marcxmltojson
+* marcxmltomarc
+* mijtomarc
+* mrktomarc
 
 
 %prep
@@ -42,6 +49,7 @@ mkdir -p $RPM_BUILD_ROOT/usr/local/bin
 
 # put the files in to the relevant directories.
 # the argument on -m is the permissions expressed as octal. (See chmod man page for details.)
+install -m 755 jsontomarc $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marccount $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcdb $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcdump $RPM_BUILD_ROOT/usr/local/bin
@@ -49,9 +57,15 @@ install -m 755 marcmap $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcsnapshot $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcsplit $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marctojson $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 marctools $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 marctosql $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marctotsv $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 marctoxml $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcuniq $RPM_BUILD_ROOT/usr/local/bin
 install -m 755 marcxmltojson $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 marcxmltomarc $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 mijtomarc $RPM_BUILD_ROOT/usr/local/bin
+install -m 755 mrktomarc $RPM_BUILD_ROOT/usr/local/bin
 
 
 %post
@@ -66,6 +80,7 @@ rm -rf %{_topdir}/BUILD/%{name}
 # list files owned by the package here
 %files
 %defattr(-,root,root)
+/usr/local/bin/jsontomarc
 /usr/local/bin/marccount
 /usr/local/bin/marcdb
 /usr/local/bin/marcdump
@@ -73,9 +88,15 @@ rm -rf %{_topdir}/BUILD/%{name}
 /usr/local/bin/marcsnapshot
 /usr/local/bin/marcsplit
 /usr/local/bin/marctojson
+/usr/local/bin/marctools
+/usr/local/bin/marctosql
 /usr/local/bin/marctotsv
+/usr/local/bin/marctoxml
 /usr/local/bin/marcuniq
 /usr/local/bin/marcxmltojson
+/usr/local/bin/marcxmltomarc
+/usr/local/bin/mijtomarc
+/usr/local/bin/mrktomarc
 
 
 %changelog
@@ -0,0 +1,416 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// RawRecord is a single binary MARC record as found in the input, along with
+// its position. The parsed record is only created on demand.
+type RawRecord struct {
+	Filename string // name of the source file, if known
+	Index    int64  // zero-based number of the record in the stream
+	Offset   int64  // byte offset of the record in the stream
+	Length   int64  // length of the record in bytes
+	Data     []byte // the raw bytes, including leader and record terminator
+
+	record *marc22.Record
+	err    error
+}
+
+// Record parses the raw bytes into a marc22.Record. The record is parsed
+// only once, subsequent calls return the same value.
+func (rr *RawRecord) Record() (*marc22.Record, error) {
+	if rr.record == nil && rr.err == nil {
+		rr.record, rr.err = ParseRecord(rr.Data)
+		if rr.err != nil {
+			rr.err = &RecordError{Filename: rr.Filename, Index: rr.Index, Offset: rr.Offset, Err: rr.err}
+		}
+	}
+	return rr.record, rr.err
+}
+
+// ControlField returns the value of the first field with the given tag,
+// without parsing the record. The field terminator is removed, ok is false if
+// there is no such field.
+func (rr *RawRecord) ControlField(tag string) (value string, ok bool, err error) {
+	return controlField(rr.Data, tag)
+}
+
+// Reader reads binary MARC records from a stream, which does not need to be
+// seekable. Each record is read exactly once.
+type Reader struct {
+	// Resync enables recovery from corrupt records. Records are checked for a
+	// plausible leader and a record terminator at the end. If a check fails,
+	// the reader skips to the next record terminator, that is followed by a
+	// plausible leader, and returns a *SkipError; reading can continue.
+	Resync bool
+	// Filename is used in records and errors, if not empty.
+	Filename string
+
+	r      *bufio.Reader
+	index  int64
+	offset int64
+	err    error
+}
+
+// NewReader returns a new reader, that reads records from r.
+func NewReader(r io.Reader) *Reader {
+	// the buffer must hold a complete record, cf. Resync
+	return &Reader{r: bufio.NewReaderSize(r, maxRecordLength+1)}
+}
+
+// Next returns the next record or io.EOF, if there are no more records. Once
+// an error occured, the position in the stream is lost and all subsequent
+// calls return the same error. With Resync enabled, corrupt data results in
+// a *SkipError, after which the reader is positioned at the next record.
+func (r *Reader) Next() (*RawRecord, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	length, data, err := r.readRecord()
+	if err != nil {
+		if serr, ok := err.(*SkipError); ok {
+			serr.Filename = r.Filename
+			return nil, err
+		}
+		if err != io.EOF {
+			err = &RecordError{Filename: r.Filename, Index: r.index, Offset: r.offset, Err: err}
+		}
+		r.err = err
+		return nil, err
+	}
+	rr := &RawRecord{Filename: r.Filename, Index: r.index, Offset: r.offset, Length: length, Data: data}
+	r.index++
+	r.offset += length
+	return rr, nil
+}
+
+// readRecord reads a leader and the rest of the record, as given by the
+// record length found in the leader.
+func (r *Reader) readRecord() (int64, []byte, error) {
+	leader, err := r.r.Peek(24)
+	if len(leader) == 0 && err == io.EOF {
+		return 0, nil, err
+	}
+	if err != nil {
+		if r.Resync && err == io.EOF {
+			return 0, nil, r.skip(leaderLengthError(io.ErrUnexpectedEOF))
+		}
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, leaderLengthError(err)
+	}
+	length, err := parseRecordLength(leader)
+	if err != nil {
+		if r.Resync {
+			return 0, nil, r.skip(err)
+		}
+		return 0, nil, err
+	}
+	if r.Resync {
+		if !plausibleLeader(leader) {
+			return 0, nil, r.skip(fmt.Errorf("marc: %w: %q", ErrInvalidLeader, leader))
+		}
+		b, err := r.r.Peek(int(length))
+		if err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		if len(b) < int(length) {
+			return 0, nil, r.skip(io.ErrUnexpectedEOF)
+		}
+		if b[length-1] != marc22.RT {
+			return 0, nil, r.skip(fmt.Errorf("%w: missing record terminator", ErrInvalidRecord))
+		}
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return length, data, nil
+}
+
+// skip discards bytes up to and including the next record terminator, that
+// is followed by a plausible leader or the end of the stream.
+func (r *Reader) skip(cause error) error {
+	var n int64
+	for {
+		b, err := r.r.ReadSlice(marc22.RT)
+		n += int64(len(b))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		next, err := r.r.Peek(24)
+		if len(next) == 0 && err == io.EOF {
+			break
+		}
+		if len(next) == 24 && plausibleLeader(next) {
+			break
+		}
+	}
+	err := &SkipError{Offset: r.offset, Length: n, Err: cause}
+	r.offset += n
+	return err
+}
+
+// plausibleLeader checks the numeric and fixed positions of a leader
+func plausibleLeader(leader []byte) bool {
+	for _, i := range []int{0, 1, 2, 3, 4, 12, 13, 14, 15, 16} {
+		if leader[i] < '0' || leader[i] > '9' {
+			return false
+		}
+	}
+	return leader[10] == '2' && leader[11] == '2' && leader[20] == '4' && leader[21] == '5'
+}
+
+// ParseLeader parses the 24 bytes of a leader. Only record length and base
+// address are required to be numeric; the remaining numeric positions default
+// to their MARC21 values.
+func ParseLeader(b []byte) (*marc22.Leader, error) {
+	if len(b) != 24 {
+		return nil, fmt.Errorf("%w: expected 24 bytes, got %d", ErrInvalidLeader, len(b))
+	}
+	length, err := strconv.Atoi(string(b[0:5]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid record length: %q", ErrInvalidLeader, b[0:5])
+	}
+	baseAddress, err := strconv.Atoi(string(b[12:17]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base address: %q", ErrInvalidLeader, b[12:17])
+	}
+	leader := &marc22.Leader{
+		Length:             length,
+		Status:             b[5],
+		Type:               b[6],
+		CharacterEncoding:  b[9],
+		BaseAddress:        baseAddress,
+		IndicatorCount:     digitOrDefault(b[10], 2),
+		SubfieldCodeLength: digitOrDefault(b[11], 2),
+		LengthOfLength:     digitOrDefault(b[20], 4),
+		LengthOfStartPos:   digitOrDefault(b[21], 5),
+	}
+	copy(leader.ImplementationDefined[0:2], b[7:9])
+	copy(leader.ImplementationDefined[2:5], b[17:20])
+	return leader, nil
+}
+
+// digitOrDefault returns the numeric value of an ASCII digit or a default
+func digitOrDefault(b byte, value int) int {
+	if b < '0' || b > '9' {
+		return value
+	}
+	return int(b - '0')
+}
+
+// ParseRecord parses a single binary MARC record, which must include the
+// leader and the record terminator. Unlike marc22.ReadRecord, the field
+// positions are taken from the directory and bytes between the indicators
+// and the first subfield delimiter are kept as a subfield with an empty
+// code, so the record can be serialized again without loss. The raw leader
+// is kept in the Leader field of the record.
+func ParseRecord(data []byte) (*marc22.Record, error) {
+	if len(data) < 25 {
+		return nil, fmt.Errorf("%w: record too short: %d bytes", ErrInvalidRecord, len(data))
+	}
+	if data[len(data)-1] != marc22.RT {
+		return nil, fmt.Errorf("%w: missing record terminator", ErrInvalidRecord)
+	}
+	leader, err := ParseLeader(data[:24])
+	if err != nil {
+		return nil, err
+	}
+	base := leader.BaseAddress
+	if base < 25 || base > len(data) || data[base-1] != marc22.RS {
+		return nil, fmt.Errorf("%w: invalid base address: %d", ErrInvalidRecord, base)
+	}
+	directory := data[24 : base-1]
+	if len(directory)%12 != 0 {
+		return nil, fmt.Errorf("%w: invalid directory length: %d", ErrInvalidRecord, len(directory))
+	}
+
+	record := &marc22.Record{
+		Leader:        string(data[:24]),
+		LeaderParsed:  leader,
+		ControlFields: make([]marc22.ControlField, 0, 8),
+		DataFields:    make([]marc22.DataField, 0, len(directory)/12),
+	}
+
+	for i := 0; i < len(directory); i += 12 {
+		entry := directory[i : i+12]
+		tag := string(entry[0:3])
+		length, err := strconv.Atoi(string(entry[3:7]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid directory entry: %q", ErrInvalidRecord, entry)
+		}
+		start, err := strconv.Atoi(string(entry[7:12]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid directory entry: %q", ErrInvalidRecord, entry)
+		}
+		start += base
+		if length < 1 || start+length > len(data)-1 {
+			return nil, fmt.Errorf("%w: field %s exceeds record", ErrInvalidRecord, tag)
+		}
+		field := data[start : start+length]
+		if field[length-1] != marc22.RS {
+			return nil, fmt.Errorf("%w: field %s does not end with a field terminator", ErrInvalidRecord, tag)
+		}
+		field = field[:length-1]
+
+		if strings.HasPrefix(tag, "00") {
+			record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: tag, Data: string(field)})
+			continue
+		}
+		if len(field) < 2 {
+			return nil, fmt.Errorf("%w: field %s has no indicators", ErrInvalidRecord, tag)
+		}
+		df := marc22.DataField{Tag: tag, Ind1: string(field[0:1]), Ind2: string(field[1:2])}
+		for i, chunk := range bytes.Split(field[2:], []byte{marc22.DELIM}) {
+			if i == 0 {
+				if len(chunk) > 0 {
+					df.SubFields = append(df.SubFields, &marc22.SubField{Value: string(chunk)})
+				}
+				continue
+			}
+			if len(chunk) == 0 {
+				continue
+			}
+			df.SubFields = append(df.SubFields, &marc22.SubField{Code: string(chunk[0:1]), Value: string(chunk[1:])})
+		}
+		record.DataFields = append(record.DataFields, df)
+	}
+	return record, nil
+}
+
+// LegacySubFields returns a copy of the record for the output formats of
+// earlier versions, which took the first byte of the data before the first
+// delimiter as its code, e.g. " " for a field starting with a blank. The
+// record itself is not changed.
+func LegacySubFields(record *marc22.Record) *marc22.Record {
+	var copied *marc22.Record
+	for i, field := range record.DataFields {
+		if len(field.SubFields) == 0 || field.SubFields[0].Code != "" || field.SubFields[0].Value == "" {
+			continue
+		}
+		if copied == nil {
+			r := *record
+			r.DataFields = append([]marc22.DataField(nil), record.DataFields...)
+			copied = &r
+		}
+		value := field.SubFields[0].Value
+		subfields := append([]*marc22.SubField{{Code: value[:1], Value: value[1:]}}, field.SubFields[1:]...)
+		copied.DataFields[i].SubFields = subfields
+	}
+	if copied == nil {
+		return record
+	}
+	return copied
+}
+
+// rawFields returns the contents of all fields with the given tag, without
+// the field terminator. Only the directory and the matching fields are looked
+// at, the record is not parsed.
+func rawFields(data []byte, tag string) ([][]byte, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("%w: record too short: %d bytes", ErrInvalidRecord, len(data))
+	}
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base address: %q", ErrInvalidLeader, data[12:17])
+	}
+	if base < 25 || base > len(data) {
+		return nil, fmt.Errorf("%w: invalid base address: %d", ErrInvalidRecord, base)
+	}
+	var fields [][]byte
+	directory := data[24 : base-1]
+	for i := 0; i+12 <= len(directory); i += 12 {
+		entry := directory[i : i+12]
+		if string(entry[0:3]) != tag {
+			continue
+		}
+		length, err := strconv.Atoi(string(entry[3:7]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid directory entry: %q", ErrInvalidRecord, entry)
+		}
+		start, err := strconv.Atoi(string(entry[7:12]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid directory entry: %q", ErrInvalidRecord, entry)
+		}
+		start += base
+		if length < 1 || start+length > len(data) {
+			return nil, fmt.Errorf("%w: field %s exceeds record", ErrInvalidRecord, tag)
+		}
+		fields = append(fields, bytes.TrimSuffix(data[start:start+length], []byte{marc22.RS}))
+	}
+	return fields, nil
+}
+
+// controlField returns the value of the first field with the given tag,
+// without parsing the whole record.
+func controlField(data []byte, tag string) (string, bool, error) {
+	fields, err := rawFields(data, tag)
+	if err != nil || len(fields) == 0 {
+		return "", false, err
+	}
+	return string(fields[0]), true, nil
+}
+
+// scanRecords reads records from r, named filename in errors, and calls fn
+// for each record. Unlike Reader, the data of the raw record points into an
+// internal buffer, which is only valid until fn returns. This avoids copying
+// records, of which only a few fields are needed.
+func scanRecords(r io.Reader, filename string, fn func(rr *RawRecord) error) error {
+	br := bufio.NewReaderSize(r, maxRecordLength+1)
+	var index, offset int64
+	fail := func(err error) error {
+		return &RecordError{Filename: filename, Index: index, Offset: offset, Err: err}
+	}
+
+	for {
+		leader, err := br.Peek(24)
+		if len(leader) == 0 && err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fail(leaderLengthError(err))
+		}
+		length, err := parseRecordLength(leader)
+		if err != nil {
+			return fail(err)
+		}
+		data, err := br.Peek(int(length))
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fail(err)
+		}
+		rr := &RawRecord{Filename: filename, Index: index, Offset: offset, Length: length, Data: data}
+		if err := fn(rr); err != nil {
+			return err
+		}
+		if _, err := br.Discard(int(length)); err != nil {
+			return fail(err)
+		}
+		index++
+		offset += length
+	}
+}
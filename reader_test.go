@@ -0,0 +1,230 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReader(t *testing.T) {
+	data, err := ioutil.ReadFile("./fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		offset int64
+		length int64
+		id     string
+	}{
+		{0, 1571, "testsample1"},
+		{1571, 1195, "testsample2"},
+		{2766, 1057, "testsample3"},
+		{3823, 1361, "testsample4"},
+		{5184, 1707, "testsample5"},
+		{6891, 1532, "testsample6"},
+		{8423, 1426, "testsample7"},
+		{9849, 1251, "testsample8"},
+		{11100, 2173, "testsample9"},
+		{13273, 1195, "testsample10"},
+	}
+
+	// a reader, that is neither seekable nor returns full reads
+	reader := NewReader(iotest.HalfReader(bytes.NewReader(data)))
+	for i, tt := range tests {
+		rr, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next() => %s", err)
+		}
+		if rr.Index != int64(i) || rr.Offset != tt.offset || rr.Length != tt.length {
+			t.Errorf("Next() => (%d, %d, %d), want: (%d, %d, %d)",
+				rr.Index, rr.Offset, rr.Length, i, tt.offset, tt.length)
+		}
+		if !bytes.Equal(rr.Data, data[tt.offset:tt.offset+tt.length]) {
+			t.Errorf("Next() raw data mismatch at offset %d", tt.offset)
+		}
+		record, err := rr.Record()
+		if err != nil {
+			t.Fatalf("Record() => %s", err)
+		}
+		if id := record.GetControlFields("001")[0].Data; id != tt.id {
+			t.Errorf("Record() => id %s, want: %s", id, tt.id)
+		}
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() => %v, want: %v", err, io.EOF)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	var tests = []struct {
+		in  string
+		err error
+	}{
+		{"00092     2200061   4500", io.ErrUnexpectedEOF},
+		{"00092", ErrInvalidLeader},
+		{"abcde     2200061   4500", ErrInvalidLeader},
+	}
+	for _, tt := range tests {
+		reader := NewReader(strings.NewReader(tt.in))
+		_, err := reader.Next()
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Next(%q) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if _, again := reader.Next(); again != err {
+			t.Errorf("Next(%q) after error => %v, want: %v", tt.in, again, err)
+		}
+	}
+
+	// framing is intact, but the record cannot be parsed
+	reader := NewReader(strings.NewReader("00026     2200025   4500x\x1d"))
+	rr, err := reader.Next()
+	if err != nil {
+		t.Fatalf("Next() => %s", err)
+	}
+	if _, err := rr.Record(); err == nil {
+		t.Errorf("Record() => nil, want: error")
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() => %v, want: %v", err, io.EOF)
+	}
+}
+
+func TestReaderResync(t *testing.T) {
+	data, err := ioutil.ReadFile("./fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// corrupt the length of the second record, append trailing garbage
+	corrupt := append([]byte{}, data...)
+	copy(corrupt[1571:1576], "01999")
+	corrupt = append(corrupt, []byte("garbage")...)
+
+	reader := NewReader(bytes.NewReader(corrupt))
+	reader.Resync = true
+
+	var offsets []int64
+	var skipped []SkipError
+	for {
+		rr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var serr *SkipError
+			if !errors.As(err, &serr) {
+				t.Fatalf("Next() => %v, want: *SkipError", err)
+			}
+			skipped = append(skipped, *serr)
+			continue
+		}
+		offsets = append(offsets, rr.Offset)
+	}
+	want := []int64{0, 2766, 3823, 5184, 6891, 8423, 9849, 11100, 13273}
+	if len(offsets) != len(want) {
+		t.Fatalf("Next() => records at %v, want: %v", offsets, want)
+	}
+	for i := range want {
+		if offsets[i] != want[i] {
+			t.Errorf("Next() => record at %d, want: %d", offsets[i], want[i])
+		}
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("Next() => %d skipped ranges, want: 2", len(skipped))
+	}
+	if skipped[0].Offset != 1571 || skipped[0].Length != 1195 {
+		t.Errorf("Next() => skipped %d bytes at %d, want: 1195 at 1571", skipped[0].Length, skipped[0].Offset)
+	}
+	if skipped[1].Offset != 14468 || skipped[1].Length != 7 {
+		t.Errorf("Next() => skipped %d bytes at %d, want: 7 at 14468", skipped[1].Length, skipped[1].Offset)
+	}
+
+	// without resync, the reader gives up
+	reader = NewReader(bytes.NewReader(corrupt))
+	reader.Next()
+	reader.Next()
+	rr, err := reader.Next()
+	if err == nil {
+		t.Errorf("Next() => record at %d, want: error", rr.Offset)
+	}
+}
+
+func TestScanRecords(t *testing.T) {
+	var tests = []struct {
+		in     string
+		values []string
+		err    error
+	}{
+		{"00042     2200037   4500001000400000\x1eabc\x1e\x1d", []string{"abc"}, nil},
+		{"00042     2200037   4500005000400000\x1eabc\x1e\x1d", []string{""}, nil},
+		{"00042     2200037   4500001009900000\x1eabc\x1e\x1d", nil, ErrInvalidRecord},
+		{"00042     2200099   4500001000400000\x1eabc\x1e\x1d", nil, ErrInvalidRecord},
+		{"00042     2200037   4500001000400000\x1eabc", nil, io.ErrUnexpectedEOF},
+		{"00042", nil, ErrInvalidLeader},
+	}
+	for _, tt := range tests {
+		var values []string
+		err := scanRecords(strings.NewReader(tt.in), "", func(rr *RawRecord) error {
+			value, ok, err := rr.ControlField("001")
+			if err != nil {
+				return err
+			}
+			if ok != (value != "") {
+				t.Errorf("ControlField(%q) => %q, %v", tt.in, value, ok)
+			}
+			values = append(values, value)
+			return nil
+		})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("scanRecords(%q) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if !reflect.DeepEqual(values, tt.values) {
+			t.Errorf("scanRecords(%q) => %q, want: %q", tt.in, values, tt.values)
+		}
+	}
+}
+
+func TestLegacySubFields(t *testing.T) {
+	// fixtures/heb.json is the output of marctojson before records were
+	// parsed with ParseRecord
+	want, err := ioutil.ReadFile("./fixtures/heb.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("./fixtures/heb.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(bytes.NewReader(data))
+	var buf bytes.Buffer
+	for {
+		rr, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, err := rr.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := MarshalRecord(record, JSONConversionOptions{MetaMap: map[string]string{}, RecordKey: "record", Charset: CharsetAuto})
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(b)
+		buf.WriteString("\n")
+		// the data before the first delimiter is kept without a code
+		if sf := record.DataFields[3].SubFields[0]; sf.Code != "" || sf.Value != " " {
+			t.Errorf("260 after MarshalRecord => %+v, want: unchanged", sf)
+		}
+	}
+	if buf.String() != string(want) {
+		t.Errorf("MarshalRecord(heb.mrc) => %s, want: %s", buf.String(), want)
+	}
+}
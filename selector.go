@@ -0,0 +1,232 @@
+package marctools
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/miku/marc22"
+)
+
+// selectorPattern matches a single term of a selector: an optional minus
+// sign, a tag, optional indicators in brackets and optional subfield codes
+var selectorPattern = regexp.MustCompile(`^(-?)([0-9A-Za-z?]{3})(\[(..)\])?(\.([a-z0-9]+))?$`)
+
+// fieldSelector matches fields by tag and indicators and selects subfields
+type fieldSelector struct {
+	tag   string // X and ? match any character
+	ind   string // two indicators, ? matches any, # a blank; empty for any
+	codes string // subfield codes, empty for all
+}
+
+// String returns the term in the notation used by ParseSelector.
+func (f fieldSelector) String() string {
+	s := f.tag
+	if f.ind != "" {
+		s += "[" + strings.Replace(f.ind, " ", "#", -1) + "]"
+	}
+	if f.codes != "" {
+		s += "." + f.codes
+	}
+	return s
+}
+
+// matchTag reports whether a tag matches, with wildcards
+func (f fieldSelector) matchTag(tag string) bool {
+	if len(tag) != len(f.tag) {
+		return false
+	}
+	for i := 0; i < len(tag); i++ {
+		switch c := f.tag[i]; c {
+		case 'X', 'x', '?':
+		default:
+			if c != tag[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchControlField reports whether a control field is selected as a whole,
+// only terms without indicators and subfield codes select control fields
+func (f fieldSelector) matchControlField(tag string) bool {
+	return f.ind == "" && f.codes == "" && f.matchTag(tag)
+}
+
+// matchDataField reports whether tag and indicators of a field match
+func (f fieldSelector) matchDataField(field marc22.DataField) bool {
+	if !f.matchTag(field.Tag) {
+		return false
+	}
+	if f.ind == "" {
+		return true
+	}
+	return matchIndicator(f.ind[0], field.Ind1) && matchIndicator(f.ind[1], field.Ind2)
+}
+
+// matchIndicator reports whether an indicator matches, ? matches any
+func matchIndicator(c byte, ind string) bool {
+	if c == '?' {
+		return true
+	}
+	b, err := indicator(ind)
+	return err == nil && b == c
+}
+
+// Selector selects fields and subfields of records. A selector is a comma
+// separated list of terms, a term is a tag, e.g. 245, in which X or ?
+// match any character, e.g. 6XX or 9??, optionally followed by indicators in
+// brackets, with ? matching any indicator and # a blank, e.g. 856[40] or
+// 856[4?], and subfield codes after a dot, e.g. 245.a or 245.ab. Terms with
+// a leading minus sign exclude fields or subfields, e.g. -9XX or -500.5.
+// Without including terms, all fields are selected. Control fields are only
+// selected by terms without indicators and subfield codes. The plain tags of
+// earlier versions, e.g. 001,245, select the same fields as before.
+type Selector struct {
+	include []fieldSelector
+	exclude []fieldSelector
+}
+
+// ParseSelector parses a selector, cf. Selector. An empty string yields a
+// selector, that selects everything.
+func ParseSelector(s string) (*Selector, error) {
+	selector := &Selector{}
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		m := selectorPattern.FindStringSubmatch(term)
+		if m == nil {
+			return nil, fmt.Errorf("%w: %q: invalid term %q", ErrInvalidSelector, s, term)
+		}
+		f := fieldSelector{tag: m[2], ind: strings.Replace(m[4], "#", " ", -1), codes: m[6]}
+		if m[1] == "-" {
+			selector.exclude = append(selector.exclude, f)
+		} else {
+			selector.include = append(selector.include, f)
+		}
+	}
+	return selector, nil
+}
+
+// String returns the selector in the notation used by ParseSelector.
+func (s *Selector) String() string {
+	var terms []string
+	for _, f := range s.include {
+		terms = append(terms, f.String())
+	}
+	for _, f := range s.exclude {
+		terms = append(terms, "-"+f.String())
+	}
+	return strings.Join(terms, ",")
+}
+
+// Empty reports whether the selector selects all fields.
+func (s *Selector) Empty() bool {
+	return len(s.include) == 0 && len(s.exclude) == 0
+}
+
+// ControlField reports whether a control field is selected.
+func (s *Selector) ControlField(tag string) bool {
+	selected := len(s.include) == 0
+	for _, f := range s.include {
+		if f.matchControlField(tag) {
+			selected = true
+			break
+		}
+	}
+	for _, f := range s.exclude {
+		if f.matchControlField(tag) {
+			return false
+		}
+	}
+	return selected
+}
+
+// DataField returns the selected subfields of a field, ok is false, if no
+// subfield is selected. Subfields without a code are kept, if all subfields
+// of the field are selected.
+func (s *Selector) DataField(field marc22.DataField) (selected marc22.DataField, ok bool) {
+	all := len(s.include) == 0
+	var codes string
+	for _, f := range s.include {
+		if !f.matchDataField(field) {
+			continue
+		}
+		if f.codes == "" {
+			all = true
+		}
+		codes += f.codes
+	}
+	if !all && codes == "" {
+		return field, false
+	}
+	var drop string
+	for _, f := range s.exclude {
+		if !f.matchDataField(field) {
+			continue
+		}
+		if f.codes == "" {
+			return field, false
+		}
+		drop += f.codes
+	}
+	if all && drop == "" {
+		return field, true
+	}
+	selected = field
+	selected.SubFields = nil
+	for _, subfield := range field.SubFields {
+		if subfield.Code == "" {
+			if all {
+				selected.SubFields = append(selected.SubFields, subfield)
+			}
+			continue
+		}
+		if (all || strings.Contains(codes, subfield.Code)) && !strings.Contains(drop, subfield.Code) {
+			selected.SubFields = append(selected.SubFields, subfield)
+		}
+	}
+	return selected, len(selected.SubFields) > 0
+}
+
+// Apply returns a record with the selected fields and subfields only. The
+// leader is kept as is. Records are shared, if everything is selected.
+func (s *Selector) Apply(record *marc22.Record) *marc22.Record {
+	if s.Empty() {
+		return record
+	}
+	selected := &marc22.Record{Leader: record.Leader, LeaderParsed: record.LeaderParsed}
+	for _, field := range record.ControlFields {
+		if s.ControlField(field.Tag) {
+			selected.ControlFields = append(selected.ControlFields, field)
+		}
+	}
+	for _, field := range record.DataFields {
+		if field, ok := s.DataField(field); ok {
+			selected.DataFields = append(selected.DataFields, field)
+		}
+	}
+	return selected
+}
+
+// Values returns the values of all selected control fields and subfields, in
+// record order.
+func (s *Selector) Values(record *marc22.Record) []string {
+	var values []string
+	for _, field := range record.ControlFields {
+		if s.ControlField(field.Tag) {
+			values = append(values, field.Data)
+		}
+	}
+	for _, field := range record.DataFields {
+		if field, ok := s.DataField(field); ok {
+			for _, subfield := range field.SubFields {
+				values = append(values, subfield.Value)
+			}
+		}
+	}
+	return values
+}
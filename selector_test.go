@@ -0,0 +1,146 @@
+package marctools
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+func TestParseSelector(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out string
+		err error
+	}{
+		{"", "", nil},
+		{"001, 245", "001,245", nil},
+		{"6XX,9??,245.a,245.bc", "6XX,9??,245.a,245.bc", nil},
+		{"856[40].u,856[4#],856[??]", "856[40].u,856[4#],856[??]", nil},
+		{"-9XX,001,-500.5", "001,-9XX,-500.5", nil},
+		{"24", "", ErrInvalidSelector},
+		{"245.", "", ErrInvalidSelector},
+		{"856[4]", "", ErrInvalidSelector},
+		{"245.A", "", ErrInvalidSelector},
+	}
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("ParseSelector(%q) => %v, want: %v", tt.in, err, tt.err)
+		}
+		if err == nil && selector.String() != tt.out {
+			t.Errorf("ParseSelector(%q).String() => %q, want: %q", tt.in, selector.String(), tt.out)
+		}
+	}
+}
+
+// selectorTestRecord has control fields 001 and 005 and data fields 245,
+// 650 (twice), 856 (twice, different indicators), 935 and 999
+var selectorTestRecord = &marc22.Record{
+	Leader: "00000nam a2200000 a 4500",
+	ControlFields: []marc22.ControlField{
+		{Tag: "001", Data: "1"},
+		{Tag: "005", Data: "20200101000000.0"},
+	},
+	DataFields: []marc22.DataField{
+		{Tag: "245", Ind1: "1", Ind2: "0", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Title :"}, {Code: "b", Value: "subtitle /"}, {Code: "c", Value: "author."}}},
+		{Tag: "650", Ind1: " ", Ind2: "0", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Psychotherapy"}, {Code: "x", Value: "Periodicals."}}},
+		{Tag: "650", Ind1: " ", Ind2: "7", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Psychotherapie"}, {Code: "2", Value: "gnd"}}},
+		{Tag: "856", Ind1: "4", Ind2: "0", SubFields: []*marc22.SubField{
+			{Code: "u", Value: "http://example.org/full"}}},
+		{Tag: "856", Ind1: "4", Ind2: "2", SubFields: []*marc22.SubField{
+			{Code: "3", Value: "Cover"}, {Code: "u", Value: "http://example.org/cover"}}},
+		{Tag: "935", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "local"}}},
+		{Tag: "999", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "local"}}},
+	},
+}
+
+func TestSelectorApply(t *testing.T) {
+	var tests = []struct {
+		selector string
+		out      string // tags and codes of the result, e.g. 245abc
+	}{
+		{"", "001 005 245abc 650ax 650a2 856u 8563u 935a 999a"},
+		{"001,245", "001 245abc"},
+		{"6XX", "650ax 650a2"},
+		{"9??", "935a 999a"},
+		{"245.a,245.b", "245ab"},
+		{"245.ab,6XX.a", "245ab 650a 650a"},
+		{"856[40]", "856u"},
+		{"856[4?].u", "856u 856u"},
+		{"650[#7]", "650a2"},
+		{"00X", "001 005"},
+		{"-9XX", "001 005 245abc 650ax 650a2 856u 8563u"},
+		{"-9XX,-00X,-6XX.2", "245abc 650ax 650a 856u 8563u"},
+		{"6XX,-650[#0]", "650a2"},
+		{"245.z", ""},
+		{"001.a", ""},
+	}
+	for _, tt := range tests {
+		selector, err := ParseSelector(tt.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := selector.Apply(selectorTestRecord)
+		var fields []string
+		for _, field := range record.ControlFields {
+			fields = append(fields, field.Tag)
+		}
+		for _, field := range record.DataFields {
+			s := field.Tag
+			for _, subfield := range field.SubFields {
+				s += subfield.Code
+			}
+			fields = append(fields, s)
+		}
+		if out := strings.Join(fields, " "); out != tt.out {
+			t.Errorf("Apply(%q) => %q, want: %q", tt.selector, out, tt.out)
+		}
+	}
+	// the record itself is not changed
+	if n := len(selectorTestRecord.DataFields[0].SubFields); n != 3 {
+		t.Errorf("subfields of 245 after Apply => %d, want: 3", n)
+	}
+}
+
+func TestSelectorValues(t *testing.T) {
+	selector, err := ParseSelector("6XX.a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := selector.Values(selectorTestRecord)
+	if want := []string{"Psychotherapy", "Psychotherapie"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Values => %v, want: %v", values, want)
+	}
+}
+
+func TestSelectorPlainTags(t *testing.T) {
+	// plain tags select the same fields as the filter maps of earlier versions
+	file, err := os.Open("./fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	record, err := marc22.ReadRecord(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := "001, 245, 650, 999"
+	selector, err := ParseSelector(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := RecordMap(selector.Apply(record), nil, false)
+	want := RecordMap(record, StringToMapSet(filter), false)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordMap with selector => %v, want: %v", got, want)
+	}
+}
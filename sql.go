@@ -0,0 +1,155 @@
+package marctools
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/miku/marc22"
+)
+
+// SQLSchema describes the tables written by SQLWriter. Fields are numbered
+// by their position in the record, control fields first, subfields by their
+// position in the field, both starting with 0.
+var SQLSchema = []string{
+	`CREATE TABLE IF NOT EXISTS record (id INTEGER PRIMARY KEY, identifier TEXT)`,
+	`CREATE TABLE IF NOT EXISTS leader (record_id INTEGER PRIMARY KEY REFERENCES record (id), raw TEXT, status TEXT, type TEXT, impldef TEXT, cs TEXT)`,
+	`CREATE TABLE IF NOT EXISTS controlfield (record_id INTEGER REFERENCES record (id), position INTEGER, tag TEXT, value TEXT)`,
+	`CREATE TABLE IF NOT EXISTS datafield (id INTEGER PRIMARY KEY, record_id INTEGER REFERENCES record (id), position INTEGER, tag TEXT, ind1 TEXT, ind2 TEXT)`,
+	`CREATE TABLE IF NOT EXISTS subfield (datafield_id INTEGER REFERENCES datafield (id), position INTEGER, code TEXT, value TEXT)`,
+}
+
+// SQLIndexes are created by SQLWriter, once all records are written.
+var SQLIndexes = []string{
+	`CREATE INDEX IF NOT EXISTS idx_record_identifier ON record (identifier)`,
+	`CREATE INDEX IF NOT EXISTS idx_controlfield_record_id ON controlfield (record_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_controlfield_tag ON controlfield (tag)`,
+	`CREATE INDEX IF NOT EXISTS idx_datafield_record_id ON datafield (record_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_datafield_tag ON datafield (tag)`,
+	`CREATE INDEX IF NOT EXISTS idx_subfield_datafield_id ON subfield (datafield_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_subfield_code ON subfield (code)`,
+}
+
+// SQLWriter writes records into normalized tables, cf. SQLSchema, within a
+// single transaction, which is committed by Close.
+type SQLWriter struct {
+	// Identifier fills record.identifier, if not nil. Records without
+	// identifier get NULL.
+	Identifier *IdentifierSpec
+
+	tx                                                *sql.Tx
+	record, leader, controlfield, datafield, subfield *sql.Stmt
+}
+
+// NewSQLWriter creates the tables, if necessary, and starts a transaction.
+func NewSQLWriter(db *sql.DB) (*SQLWriter, error) {
+	for _, s := range SQLSchema {
+		if _, err := db.Exec(s); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, s)
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	w := &SQLWriter{tx: tx}
+	for _, v := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.record, "INSERT INTO record (identifier) VALUES (?)"},
+		{&w.leader, "INSERT INTO leader VALUES (?, ?, ?, ?, ?, ?)"},
+		{&w.controlfield, "INSERT INTO controlfield VALUES (?, ?, ?, ?)"},
+		{&w.datafield, "INSERT INTO datafield (record_id, position, tag, ind1, ind2) VALUES (?, ?, ?, ?, ?)"},
+		{&w.subfield, "INSERT INTO subfield VALUES (?, ?, ?, ?)"},
+	} {
+		if *v.stmt, err = tx.Prepare(v.query); err != nil {
+			w.Rollback()
+			return nil, fmt.Errorf("%w: %s", err, v.query)
+		}
+	}
+	return w, nil
+}
+
+// Write inserts a single record.
+func (w *SQLWriter) Write(record *marc22.Record) error {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return err
+	}
+	var identifier sql.NullString
+	if w.Identifier != nil {
+		id, err := w.Identifier.Identifier(record)
+		switch {
+		case err == nil:
+			identifier = sql.NullString{String: id, Valid: true}
+		case !errors.Is(err, ErrMissingIdentifier):
+			return err
+		}
+	}
+	result, err := w.record.Exec(identifier)
+	if err != nil {
+		return err
+	}
+	recordID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	_, err = w.leader.Exec(recordID, string(leader), string(leader[5]), string(leader[6]),
+		string(leader[7:9])+string(leader[17:20]), string(leader[9]))
+	if err != nil {
+		return err
+	}
+
+	var position int
+	for _, field := range record.ControlFields {
+		if _, err := w.controlfield.Exec(recordID, position, field.Tag, field.Data); err != nil {
+			return err
+		}
+		position++
+	}
+	for _, field := range record.DataFields {
+		result, err := w.datafield.Exec(recordID, position, field.Tag, field.Ind1, field.Ind2)
+		if err != nil {
+			return err
+		}
+		fieldID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for i, subfield := range field.SubFields {
+			if _, err := w.subfield.Exec(fieldID, i, subfield.Code, subfield.Value); err != nil {
+				return err
+			}
+		}
+		position++
+	}
+	return nil
+}
+
+// closeStatements releases the prepared statements
+func (w *SQLWriter) closeStatements() {
+	for _, stmt := range []*sql.Stmt{w.record, w.leader, w.controlfield, w.datafield, w.subfield} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// Rollback discards all records written so far.
+func (w *SQLWriter) Rollback() error {
+	w.closeStatements()
+	return w.tx.Rollback()
+}
+
+// Close creates the indexes and commits the transaction.
+func (w *SQLWriter) Close() error {
+	w.closeStatements()
+	for _, s := range SQLIndexes {
+		if _, err := w.tx.Exec(s); err != nil {
+			w.tx.Rollback()
+			return fmt.Errorf("%w: %s", err, s)
+		}
+	}
+	return w.tx.Commit()
+}
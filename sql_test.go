@@ -0,0 +1,133 @@
+package marctools
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+// sqlRecord reads a record back from the tables written by SQLWriter
+func sqlRecord(db *sql.DB, id int64) (*marc22.Record, error) {
+	record := &marc22.Record{}
+	if err := db.QueryRow("SELECT raw FROM leader WHERE record_id = ?", id).Scan(&record.Leader); err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("SELECT tag, value FROM controlfield WHERE record_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var field marc22.ControlField
+		if err := rows.Scan(&field.Tag, &field.Data); err != nil {
+			return nil, err
+		}
+		record.ControlFields = append(record.ControlFields, field)
+	}
+	rows, err = db.Query(`SELECT d.id, d.tag, d.ind1, d.ind2, s.code, s.value FROM datafield d
+		JOIN subfield s ON s.datafield_id = d.id WHERE d.record_id = ? ORDER BY d.position, s.position`, id)
+	if err != nil {
+		return nil, err
+	}
+	var last int64
+	for rows.Next() {
+		var fieldID int64
+		var field marc22.DataField
+		var subfield marc22.SubField
+		if err := rows.Scan(&fieldID, &field.Tag, &field.Ind1, &field.Ind2, &subfield.Code, &subfield.Value); err != nil {
+			return nil, err
+		}
+		if fieldID != last {
+			record.DataFields = append(record.DataFields, field)
+			last = fieldID
+		}
+		f := &record.DataFields[len(record.DataFields)-1]
+		f.SubFields = append(f.SubFields, &subfield)
+	}
+	return record, rows.Err()
+}
+
+func TestSQLWriter(t *testing.T) {
+	file, err := ioutil.TempFile("", "marctools-TestSQLWriter-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	db, err := sql.Open("sqlite3", file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	w, err := NewSQLWriter(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Identifier = DefaultIdentifierSpec
+
+	input, err := os.Open("./fixtures/journals.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+
+	var data [][]byte
+	reader := NewReader(input)
+	for {
+		rr, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		record, err := rr.Record()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, rr.Data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var id int64
+	err = db.QueryRow("SELECT id FROM record WHERE identifier = ?", "testsample3").Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 {
+		t.Errorf("id of testsample3 => %d, want: 3", id)
+	}
+	var count int
+	err = db.QueryRow(`SELECT COUNT(DISTINCT d.record_id) FROM datafield d
+		JOIN subfield s ON s.datafield_id = d.id WHERE d.tag = '650' AND s.code = 'a' AND s.value = 'Psychotherapy'`).Scan(&count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("records with 650.a Psychotherapy => %d, want: 2", count)
+	}
+
+	// the first record reads back unchanged
+	record, err := sqlRecord(db, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data[0]) {
+		t.Errorf("record 1 => %q, want: %q", b, data[0])
+	}
+}
@@ -0,0 +1,174 @@
+package marctools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/miku/marc22"
+)
+
+const (
+	maxFieldLength  = 9999  // four digits length of field
+	maxRecordLength = 99999 // five digits record length and starting position
+)
+
+// leaderBytes returns the leader for a record. The raw leader (if any) is
+// used as a template, the values of the parsed leader take precedence. The
+// positions, that depend on the record structure are left for the caller.
+func leaderBytes(record *marc22.Record) ([]byte, error) {
+	lp := record.LeaderParsed
+	var buf []byte
+	switch {
+	case len(record.Leader) == 24:
+		buf = []byte(record.Leader)
+	case lp != nil:
+		buf = lp.Bytes()
+	default:
+		return nil, fmt.Errorf("%w: record has no leader", ErrInvalidLeader)
+	}
+	if lp != nil {
+		setNonZero(buf[5:6], lp.Status)
+		setNonZero(buf[6:7], lp.Type)
+		setNonZero(buf[7:9], lp.ImplementationDefined[0:2]...)
+		setNonZero(buf[9:10], lp.CharacterEncoding)
+		setNonZero(buf[17:20], lp.ImplementationDefined[2:5]...)
+	}
+	// indicator count, subfield code length, entry map
+	buf[10], buf[11], buf[20], buf[21] = '2', '2', '4', '5'
+	return buf, nil
+}
+
+// parseLeaderTemplate parses a leader, that is used as a template for
+// serialization; record length and base address are not checked, since they
+// are recomputed anyway
+func parseLeaderTemplate(s string) (*marc22.Leader, error) {
+	if len(s) != 24 {
+		return nil, fmt.Errorf("%w: expected 24 bytes, got %d", ErrInvalidLeader, len(s))
+	}
+	b := []byte(s)
+	copy(b[0:5], "00000")
+	copy(b[12:17], "00000")
+	return ParseLeader(b)
+}
+
+// setNonZero copies all non-zero values into dst
+func setNonZero(dst []byte, values ...byte) {
+	for i, v := range values {
+		if v != 0 {
+			dst[i] = v
+		}
+	}
+}
+
+// indicator returns a single indicator byte, blank if empty
+func indicator(s string) (byte, error) {
+	switch len(s) {
+	case 0:
+		return ' ', nil
+	case 1:
+		return s[0], nil
+	default:
+		return 0, fmt.Errorf("invalid indicator: %q", s)
+	}
+}
+
+// Marshal serializes a record into binary MARC (ISO 2709). Record length,
+// base address and directory are computed, all other leader positions are
+// taken from the record.
+func Marshal(record *marc22.Record) ([]byte, error) {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return nil, err
+	}
+
+	var directory, data bytes.Buffer
+
+	addField := func(tag string, field []byte) error {
+		if len(tag) != 3 {
+			return fmt.Errorf("invalid tag: %q", tag)
+		}
+		if len(field) > maxFieldLength {
+			return fmt.Errorf("%w: %s has %d bytes", ErrFieldTooLong, tag, len(field))
+		}
+		if data.Len() > maxRecordLength {
+			return fmt.Errorf("%w: starting position of %s exceeds %d", ErrRecordTooLong, tag, maxRecordLength)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(field), data.Len())
+		data.Write(field)
+		return nil
+	}
+
+	for _, field := range record.ControlFields {
+		b := make([]byte, 0, len(field.Data)+1)
+		b = append(b, field.Data...)
+		b = append(b, marc22.RS)
+		if err := addField(field.Tag, b); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range record.DataFields {
+		ind1, err := indicator(field.Ind1)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		ind2, err := indicator(field.Ind2)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field.Tag, err)
+		}
+		b := []byte{ind1, ind2}
+		for i, subfield := range field.SubFields {
+			switch {
+			case len(subfield.Code) == 1:
+				b = append(b, marc22.DELIM, subfield.Code[0])
+			case len(subfield.Code) == 0 && i == 0:
+				// data before the first delimiter, cf. ParseRecord
+			default:
+				return nil, fmt.Errorf("%s: invalid subfield code: %q", field.Tag, subfield.Code)
+			}
+			b = append(b, subfield.Value...)
+		}
+		b = append(b, marc22.RS)
+		if err := addField(field.Tag, b); err != nil {
+			return nil, err
+		}
+	}
+	directory.WriteByte(marc22.RS)
+
+	baseAddress := 24 + directory.Len()
+	length := baseAddress + data.Len() + 1
+	if length > maxRecordLength {
+		return nil, fmt.Errorf("%w: %d bytes", ErrRecordTooLong, length)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", length))
+	copy(leader[12:17], fmt.Sprintf("%05d", baseAddress))
+
+	b := make([]byte, 0, length)
+	b = append(b, leader...)
+	b = append(b, directory.Bytes()...)
+	b = append(b, data.Bytes()...)
+	b = append(b, marc22.RT)
+	return b, nil
+}
+
+// Writer writes binary MARC records to an underlying writer.
+type Writer struct {
+	w io.Writer
+}
+
+// NewWriter returns a new writer, that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Write serializes a single record. Nothing is written, if the record
+// cannot be represented in ISO 2709.
+func (w *Writer) Write(record *marc22.Record) error {
+	b, err := Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.w.Write(b)
+	return err
+}
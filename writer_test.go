@@ -0,0 +1,105 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := Marshal(record)
+			if err != nil {
+				t.Errorf("Marshal(%s, %d) => %s", filename, rr.Index, err)
+				continue
+			}
+			if !bytes.Equal(b, rr.Data) {
+				t.Errorf("Marshal(%s, %d) => %q, want: %q", filename, rr.Index, b, rr.Data)
+			}
+		}
+		file.Close()
+	}
+}
+
+func TestMarshalModified(t *testing.T) {
+	record, err := marc22.ReadRecord(strings.NewReader(recordMapTests[3].record))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.LeaderParsed.Status = 'd'
+	record.ControlFields[0].Data = "123456789"
+	record.DataFields = append(record.DataFields, marc22.DataField{
+		Tag:       "245",
+		Ind1:      "1",
+		SubFields: []*marc22.SubField{{Code: "a", Value: "Title"}},
+	})
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(record); err != nil {
+		t.Fatal(err)
+	}
+	want := "00118d    2200073   4500" +
+		"001001000000040001200010040001200022245001000034\x1e" +
+		"123456789\x1e  \x1faValue 1\x1e  \x1faValue 2\x1e1 \x1faTitle\x1e\x1d"
+	if buf.String() != want {
+		t.Errorf("Write() => %q, want: %q", buf.String(), want)
+	}
+
+	parsed, err := marc22.ReadRecord(&buf)
+	if err != nil {
+		t.Fatalf("ReadRecord(Write()) => %s", err)
+	}
+	if parsed.LeaderParsed.Length != 118 || parsed.LeaderParsed.BaseAddress != 73 {
+		t.Errorf("ReadRecord(Write()) => length %d, base address %d, want: 118, 73",
+			parsed.LeaderParsed.Length, parsed.LeaderParsed.BaseAddress)
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	var tests = []struct {
+		record *marc22.Record
+		err    error
+	}{
+		{&marc22.Record{}, ErrInvalidLeader},
+		{&marc22.Record{
+			Leader:     "00000nam a2200000   4500",
+			DataFields: []marc22.DataField{{Tag: "500", SubFields: []*marc22.SubField{{Code: "a", Value: strings.Repeat("x", 10000)}}}},
+		}, ErrFieldTooLong},
+		{&marc22.Record{
+			Leader:        "00000nam a2200000   4500",
+			ControlFields: []marc22.ControlField{{Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}, {Tag: "001", Data: strings.Repeat("x", 9000)}},
+		}, ErrRecordTooLong},
+	}
+	for _, tt := range tests {
+		if _, err := Marshal(tt.record); !errors.Is(err, tt.err) {
+			t.Errorf("Marshal() => %v, want: %v", err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package marctools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/miku/marc22"
+)
+
+// MARCXMLNamespace is the namespace of MARC 21 XML
+const MARCXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+// Start and end of a MARCXML collection, records go in between.
+const (
+	XMLCollectionStart = xml.Header + `<collection xmlns="` + MARCXMLNamespace + `">` + "\n"
+	XMLCollectionEnd   = "</collection>\n"
+)
+
+// XML output formats, cf. XMLConversionOptions
+const (
+	FormatMARCXML = "marcxml"
+	FormatDC      = "dc"   // simple Dublin Core, oai_dc
+	FormatMODS    = "mods" // MODS 3.7
+)
+
+// XMLCollection returns the start and end of a collection of records in the
+// given XML format.
+func XMLCollection(format string) (start, end string, err error) {
+	switch format {
+	case "", FormatMARCXML:
+		return XMLCollectionStart, XMLCollectionEnd, nil
+	case FormatDC:
+		return DCCollectionStart, DCCollectionEnd, nil
+	case FormatMODS:
+		return MODSCollectionStart, MODSCollectionEnd, nil
+	}
+	return "", "", fmt.Errorf("unknown format: %s", format)
+}
+
+// XMLConversionOptions specify parameters for the MARC to MARCXML conversion
+type XMLConversionOptions struct {
+	Format       string          // output format, MARCXML if empty, cf. FormatDC
+	FilterMap    map[string]bool // which tags to include, MARCXML only
+	Selector     *Selector       // which fields and subfields to include, MARCXML only
+	Indent       bool            // indent elements
+	IgnoreErrors bool
+	Charset      string // source charset, cf. ToUTF8; empty for no conversion
+	// Errors receives conversion errors, cf. JSONConversionOptions
+	Errors chan<- error
+}
+
+// handleError logs an error, if errors should be ignored. Otherwise the
+// error is passed to the errors channel, or, if there is none, the program exits.
+func (options XMLConversionOptions) handleError(err error) {
+	switch {
+	case options.IgnoreErrors:
+		log.Println(err)
+	case options.Errors == nil:
+		log.Fatal(err)
+	default:
+		options.Errors <- err
+	}
+}
+
+// isXMLChar reports whether a rune is allowed in XML 1.0 documents
+func isXMLChar(r rune) bool {
+	switch {
+	case r == '\t' || r == '\n' || r == '\r':
+		return true
+	case r >= 0x20 && r <= 0xD7FF:
+		return true
+	case r >= 0xE000 && r <= 0xFFFD:
+		return true
+	case r >= 0x10000 && r <= utf8.MaxRune:
+		return true
+	}
+	return false
+}
+
+// validXMLPrefix returns the length of the longest prefix of s, that is
+// valid UTF-8 and consists of runes allowed in XML 1.0 only
+func validXMLPrefix(s string) int {
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if !isXMLChar(r) || (r == utf8.RuneError && size == 1) {
+			return i
+		}
+		i += size
+	}
+	return len(s)
+}
+
+// stripInvalidXML removes runes, that are not allowed in XML 1.0, e.g.
+// control characters, as well as invalid UTF-8
+func stripInvalidXML(s string) string {
+	n := validXMLPrefix(s)
+	if n == len(s) {
+		return s
+	}
+	var sb strings.Builder
+	for n < len(s) {
+		sb.WriteString(s[:n])
+		_, size := utf8.DecodeRuneInString(s[n:])
+		s = s[n+size:]
+		n = validXMLPrefix(s)
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// xmlText writes escaped character data
+func xmlText(buf *bytes.Buffer, s string) {
+	xml.EscapeText(buf, []byte(stripInvalidXML(s)))
+}
+
+// MarshalXML serializes a single record to a MARCXML record element, without
+// XML declaration or collection. Characters not allowed in XML 1.0 are
+// removed. If filter is not empty, only the given tags are included. Data
+// before the first subfield delimiter has no code, it cannot be written to
+// MARCXML and is skipped.
+func MarshalXML(record *marc22.Record, filter map[string]bool, indent bool) ([]byte, error) {
+	leader, err := leaderBytes(record)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	nl := func(level int) {
+		if indent {
+			buf.WriteString("\n")
+			buf.WriteString(strings.Repeat("  ", level))
+		}
+	}
+	included := func(tag string) bool {
+		return len(filter) == 0 || filter[tag]
+	}
+
+	buf.WriteString("<record>")
+	nl(1)
+	buf.WriteString("<leader>")
+	xmlText(&buf, string(leader))
+	buf.WriteString("</leader>")
+
+	for _, field := range record.ControlFields {
+		if !included(field.Tag) {
+			continue
+		}
+		nl(1)
+		buf.WriteString(`<controlfield tag="`)
+		xmlText(&buf, field.Tag)
+		buf.WriteString(`">`)
+		xmlText(&buf, field.Data)
+		buf.WriteString("</controlfield>")
+	}
+
+	for _, field := range record.DataFields {
+		if !included(field.Tag) {
+			continue
+		}
+		ind1, err := indicator(field.Ind1)
+		if err != nil {
+			return nil, err
+		}
+		ind2, err := indicator(field.Ind2)
+		if err != nil {
+			return nil, err
+		}
+		nl(1)
+		buf.WriteString(`<datafield tag="`)
+		xmlText(&buf, field.Tag)
+		buf.WriteString(`" ind1="`)
+		xmlText(&buf, string(ind1))
+		buf.WriteString(`" ind2="`)
+		xmlText(&buf, string(ind2))
+		buf.WriteString(`">`)
+		for _, subfield := range field.SubFields {
+			if subfield.Code == "" {
+				continue
+			}
+			nl(2)
+			buf.WriteString(`<subfield code="`)
+			xmlText(&buf, subfield.Code)
+			buf.WriteString(`">`)
+			xmlText(&buf, subfield.Value)
+			buf.WriteString("</subfield>")
+		}
+		nl(1)
+		buf.WriteString("</datafield>")
+	}
+	nl(0)
+	buf.WriteString("</record>")
+	return buf.Bytes(), nil
+}
+
+// MarshalRecordXML converts a record to UTF-8 and serializes it to MARCXML,
+// Dublin Core or MODS according to the given options.
+func MarshalRecordXML(record *marc22.Record, options XMLConversionOptions) ([]byte, error) {
+	if err := ToUTF8(record, options.Charset); err != nil {
+		return nil, err
+	}
+	switch options.Format {
+	case "", FormatMARCXML:
+		if options.Selector != nil {
+			record = options.Selector.Apply(record)
+		}
+		return MarshalXML(record, options.FilterMap, options.Indent)
+	case FormatDC:
+		return MarshalDC(record, options.Indent)
+	case FormatMODS:
+		return MarshalMODS(record, options.Indent)
+	}
+	return nil, fmt.Errorf("unknown format: %s", options.Format)
+}
+
+// OrderedXMLBatchWorker converts numbered batches of MARC records to XML, cf.
+// Sequencer, which restores the order of the batches.
+func OrderedXMLBatchWorker(in chan Batch, out chan BatchResult, wg *sync.WaitGroup, options XMLConversionOptions) {
+	defer wg.Done()
+	for batch := range in {
+		result := BatchResult{Seq: batch.Seq, Items: make([][]byte, 0, len(batch.Records))}
+		for _, record := range batch.Records {
+			b, err := MarshalRecordXML(record, options)
+			if err != nil {
+				options.handleError(err)
+				continue
+			}
+			result.Items = append(result.Items, b)
+		}
+		out <- result
+	}
+}
+
+// XMLWriter writes records as a MARCXML collection to an underlying writer.
+// Call Close to end the collection.
+type XMLWriter struct {
+	FilterMap map[string]bool // which tags to include
+	Indent    bool            // indent elements
+
+	w       io.Writer
+	started bool
+}
+
+// NewXMLWriter returns a new writer, that writes to w.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{w: w}
+}
+
+// start writes the beginning of the collection, once
+func (w *XMLWriter) start() error {
+	if w.started {
+		return nil
+	}
+	w.started = true
+	_, err := io.WriteString(w.w, XMLCollectionStart)
+	return err
+}
+
+// Write serializes a single record, which should be UTF-8 encoded.
+func (w *XMLWriter) Write(record *marc22.Record) error {
+	b, err := MarshalXML(record, w.FilterMap, w.Indent)
+	if err != nil {
+		return err
+	}
+	if err := w.start(); err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	_, err = w.w.Write(b)
+	return err
+}
+
+// Close ends the collection. It does not close the underlying writer.
+func (w *XMLWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, XMLCollectionEnd)
+	return err
+}
@@ -0,0 +1,138 @@
+package marctools
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+var xmlTestRecord = &marc22.Record{
+	Leader: "00000nam a2200000 a 4500",
+	ControlFields: []marc22.ControlField{
+		{Tag: "001", Data: "id\x01 1"},
+		{Tag: "005", Data: "20150101"},
+	},
+	DataFields: []marc22.DataField{
+		{Tag: "245", Ind1: "1", SubFields: []*marc22.SubField{
+			{Code: "a", Value: "Fish & <Chips>\x1b"},
+			{Code: "b", Value: "caf\xc3\xa9 \xff"},
+		}},
+	},
+}
+
+func TestMarshalXML(t *testing.T) {
+	var tests = []struct {
+		filter map[string]bool
+		indent bool
+		out    string
+	}{
+		{nil, false, `<record><leader>00000nam a2200000 a 4500</leader>` +
+			`<controlfield tag="001">id 1</controlfield>` +
+			`<controlfield tag="005">20150101</controlfield>` +
+			`<datafield tag="245" ind1="1" ind2=" ">` +
+			`<subfield code="a">Fish &amp; &lt;Chips&gt;</subfield>` +
+			`<subfield code="b">café </subfield></datafield></record>`},
+		{map[string]bool{"001": true}, true, "<record>\n" +
+			"  <leader>00000nam a2200000 a 4500</leader>\n" +
+			"  <controlfield tag=\"001\">id 1</controlfield>\n" +
+			"</record>"},
+	}
+
+	for _, tt := range tests {
+		b, err := MarshalXML(xmlTestRecord, tt.filter, tt.indent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.out {
+			t.Errorf("MarshalXML(%v, %v) => %s, want: %s", tt.filter, tt.indent, b, tt.out)
+		}
+	}
+}
+
+func TestMarshalXMLCodelessSubField(t *testing.T) {
+	// data before the first delimiter, as kept by ParseRecord
+	record := &marc22.Record{
+		Leader: "00000nam a2200000 a 4500",
+		DataFields: []marc22.DataField{
+			{Tag: "260", Ind1: " ", Ind2: " ", SubFields: []*marc22.SubField{
+				{Value: " "}, {Code: "a", Value: "Jerusalem"},
+			}},
+		},
+	}
+	b, err := MarshalXML(record, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<record><leader>00000nam a2200000 a 4500</leader>` +
+		`<datafield tag="260" ind1=" " ind2=" "><subfield code="a">Jerusalem</subfield></datafield></record>`
+	if string(b) != want {
+		t.Errorf("MarshalXML => %s, want: %s", b, want)
+	}
+}
+
+func TestXMLWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewXMLWriter(&buf)
+	w.Indent = true
+	for i := 0; i < 2; i++ {
+		if err := w.Write(xmlTestRecord); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var collection struct {
+		XMLName xml.Name
+		Records []marc22.Record `xml:"record"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatal(err)
+	}
+	if collection.XMLName.Space != MARCXMLNamespace || collection.XMLName.Local != "collection" {
+		t.Errorf("XMLWriter: got root element %v", collection.XMLName)
+	}
+	if len(collection.Records) != 2 {
+		t.Fatalf("XMLWriter: got %d records, want: 2", len(collection.Records))
+	}
+	var values []string
+	for _, sf := range collection.Records[1].GetSubFields("245", "a") {
+		values = append(values, sf.Value)
+	}
+	if want := []string{"Fish & <Chips>"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("XMLWriter: got 245.a %q, want: %q", values, want)
+	}
+}
+
+func TestMarshalRecordXMLFormat(t *testing.T) {
+	for _, format := range []string{"", FormatMARCXML, FormatDC, FormatMODS} {
+		start, end, err := XMLCollection(format)
+		if err != nil {
+			t.Fatalf("XMLCollection(%q) => %v", format, err)
+		}
+		b, err := MarshalRecordXML(xmlTestRecord, XMLConversionOptions{Format: format, Charset: "utf8"})
+		if err != nil {
+			t.Fatalf("MarshalRecordXML(%q) => %v", format, err)
+		}
+		dec := xml.NewDecoder(strings.NewReader(start + string(b) + end))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s collection is not well-formed: %v", format, err)
+				break
+			}
+		}
+	}
+	if _, _, err := XMLCollection("html"); err == nil {
+		t.Errorf("XMLCollection(html) => nil error")
+	}
+}
@@ -0,0 +1,167 @@
+package marctools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"github.com/miku/marc22"
+)
+
+// lineReader counts the lines of the bytes read. Since it is an
+// io.ByteReader, xml.Decoder reads from it without buffering, so the line
+// number is exact.
+type lineReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (r *lineReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil && b == '\n' {
+		r.line++
+	}
+	return b, err
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.line += bytes.Count(p[:n], []byte("\n"))
+	return n, err
+}
+
+// xmlRecord is a MARCXML record element; element names match regardless of
+// namespace prefixes
+type xmlRecord struct {
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag  string `xml:"tag,attr"`
+	Data string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	SubFields []xmlSubField `xml:"subfield"`
+}
+
+type xmlSubField struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// isMARCXMLRecord reports whether an element is a MARCXML record; records
+// without namespace are accepted as well, records of other namespaces,
+// e.g. OAI-PMH, are not
+func isMARCXMLRecord(name xml.Name) bool {
+	return name.Local == "record" && (name.Space == MARCXMLNamespace || name.Space == "")
+}
+
+// record converts the element into a record. The leader is parsed; if
+// record length or base address are not numeric, they are set to zero.
+func (r *xmlRecord) record() (*marc22.Record, error) {
+	leader, err := ParseLeader([]byte(r.Leader))
+	if err != nil {
+		if leader, err = parseLeaderTemplate(r.Leader); err != nil {
+			return nil, err
+		}
+	}
+	record := &marc22.Record{
+		Leader:        r.Leader,
+		LeaderParsed:  leader,
+		ControlFields: make([]marc22.ControlField, 0, len(r.ControlFields)),
+		DataFields:    make([]marc22.DataField, 0, len(r.DataFields)),
+	}
+	for _, f := range r.ControlFields {
+		if len(f.Tag) != 3 {
+			return nil, fmt.Errorf("%w: invalid tag: %q", ErrInvalidRecord, f.Tag)
+		}
+		record.ControlFields = append(record.ControlFields, marc22.ControlField{Tag: f.Tag, Data: f.Data})
+	}
+	for _, f := range r.DataFields {
+		if len(f.Tag) != 3 {
+			return nil, fmt.Errorf("%w: invalid tag: %q", ErrInvalidRecord, f.Tag)
+		}
+		if len(f.Ind1) > 1 || len(f.Ind2) > 1 {
+			return nil, fmt.Errorf("%w: %s: invalid indicators: %q, %q", ErrInvalidRecord, f.Tag, f.Ind1, f.Ind2)
+		}
+		field := marc22.DataField{Tag: f.Tag, Ind1: f.Ind1, Ind2: f.Ind2, SubFields: make([]*marc22.SubField, 0, len(f.SubFields))}
+		for i, sf := range f.SubFields {
+			// an empty code keeps data before the first delimiter, cf. ParseRecord
+			if len(sf.Code) != 1 && !(sf.Code == "" && i == 0) {
+				return nil, fmt.Errorf("%w: %s: invalid subfield code: %q", ErrInvalidRecord, f.Tag, sf.Code)
+			}
+			field.SubFields = append(field.SubFields, &marc22.SubField{Code: sf.Code, Value: sf.Value})
+		}
+		record.DataFields = append(record.DataFields, field)
+	}
+	return record, nil
+}
+
+// XMLReader reads MARCXML records from a stream. Records are found anywhere
+// in the document, e.g. in a collection or an OAI-PMH response; only record
+// elements in the MARCXML namespace or without namespace are used.
+type XMLReader struct {
+	// Filename is used in errors, if not empty.
+	Filename string
+
+	dec   *xml.Decoder
+	lr    *lineReader
+	index int64
+	err   error
+}
+
+// NewXMLReader returns a reader, that reads from r.
+func NewXMLReader(r io.Reader) *XMLReader {
+	lr := &lineReader{r: bufio.NewReader(r), line: 1}
+	return &XMLReader{dec: xml.NewDecoder(lr), lr: lr}
+}
+
+// Next returns the next record or io.EOF. Invalid records are reported as
+// *RecordError and reading can continue. Other errors, e.g. XML syntax
+// errors, are final, later calls return the same error.
+func (r *XMLReader) Next() (*marc22.Record, error) {
+	for r.err == nil {
+		t, err := r.dec.Token()
+		if err != nil {
+			r.fail(err)
+			break
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || !isMARCXMLRecord(se.Name) {
+			continue
+		}
+		index, line := r.index, r.lr.line
+		r.index++
+		var xr xmlRecord
+		if err := r.dec.DecodeElement(&xr, &se); err != nil {
+			r.fail(err)
+			break
+		}
+		record, err := xr.record()
+		if err != nil {
+			return nil, &RecordError{Filename: r.Filename, Index: index, Offset: -1, Line: line, Err: err}
+		}
+		return record, nil
+	}
+	return nil, r.err
+}
+
+// fail records a final error, with location information
+func (r *XMLReader) fail(err error) {
+	switch {
+	case err == io.EOF:
+		r.err = err
+	case r.Filename != "":
+		r.err = fmt.Errorf("%s: line %d: %w: %s", r.Filename, r.lr.line, ErrInvalidRecord, err)
+	default:
+		r.err = fmt.Errorf("line %d: %w: %s", r.lr.line, ErrInvalidRecord, err)
+	}
+}
@@ -0,0 +1,198 @@
+package marctools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/miku/marc22"
+)
+
+const xmlReaderRecord = `<record><leader>00000nam a2200000 a 4500</leader>
+<controlfield tag="001">%s</controlfield>
+<datafield tag="245" ind1="1" ind2="0"><subfield code="a">Title</subfield></datafield>
+</record>`
+
+func xmlRecordWithID(id string) string {
+	return strings.Replace(xmlReaderRecord, "%s", id, 1)
+}
+
+func TestXMLReader(t *testing.T) {
+	var tests = []struct {
+		about string
+		in    string
+		ids   []string
+		lines []int // lines of record errors
+		err   error // final error
+	}{
+		{"bare record", xmlRecordWithID("1"), []string{"1"}, nil, nil},
+		{"collection", `<?xml version="1.0"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">` + xmlRecordWithID("1") + xmlRecordWithID("2") + `</collection>`,
+			[]string{"1", "2"}, nil, nil},
+		{"prefix", `<marc:collection xmlns:marc="http://www.loc.gov/MARC21/slim">
+<marc:record><marc:leader>00000nam a2200000 a 4500</marc:leader>
+<marc:controlfield tag="001">1</marc:controlfield></marc:record></marc:collection>`,
+			[]string{"1"}, nil, nil},
+		{"oai-pmh", `<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/"><ListRecords>
+<record><header><identifier>oai:1</identifier></header>
+<metadata><record xmlns="http://www.loc.gov/MARC21/slim">
+<leader>00000nam a2200000 a 4500</leader><controlfield tag="001">1</controlfield>
+</record></metadata></record>
+<record><header status="deleted"><identifier>oai:2</identifier></header></record>
+</ListRecords></OAI-PMH>`, []string{"1"}, nil, nil},
+		{"other namespace", `<r xmlns:x="http://example.com/"><x:record><x:leader>x</x:leader></x:record></r>`,
+			nil, nil, nil},
+		{"invalid records", `<collection>
+<record><leader>short</leader></record>
+` + xmlRecordWithID("1") + `
+<record><leader>00000nam a2200000 a 4500</leader>
+<datafield tag="245" ind1="10" ind2="0"></datafield></record>
+` + xmlRecordWithID("2") + `</collection>`,
+			[]string{"1", "2"}, []int{2, 7}, nil},
+		{"syntax error", `<collection>
+` + xmlRecordWithID("1") + `
+<record><leader>00000nam a2200000 a 4500</leader></recor>`,
+			[]string{"1"}, nil, ErrInvalidRecord},
+	}
+
+	for _, tt := range tests {
+		reader := NewXMLReader(strings.NewReader(tt.in))
+		var ids []string
+		var lines []int
+		var final error
+		for {
+			record, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			var rerr *RecordError
+			if errors.As(err, &rerr) {
+				lines = append(lines, rerr.Line)
+				continue
+			}
+			if err != nil {
+				final = err
+				break
+			}
+			for _, f := range record.GetControlFields("001") {
+				ids = append(ids, f.Data)
+			}
+			if record.LeaderParsed == nil || record.LeaderParsed.Type != 'a' {
+				t.Errorf("%s: leader not parsed: %v", tt.about, record.LeaderParsed)
+			}
+		}
+		if !reflect.DeepEqual(ids, tt.ids) {
+			t.Errorf("%s: got ids %v, want: %v", tt.about, ids, tt.ids)
+		}
+		if !reflect.DeepEqual(lines, tt.lines) {
+			t.Errorf("%s: got errors at lines %v, want: %v", tt.about, lines, tt.lines)
+		}
+		if !errors.Is(final, tt.err) {
+			t.Errorf("%s: got %v, want: %v", tt.about, final, tt.err)
+		}
+	}
+}
+
+func TestXMLReaderRoundTrip(t *testing.T) {
+	var b strings.Builder
+	w := NewXMLWriter(&b)
+	if err := w.Write(xmlTestRecord); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	record, err := NewXMLReader(strings.NewReader(b.String())).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := record.DataFields[0].SubFields[0].Value, "Fish & <Chips>"; got != want {
+		t.Errorf("XMLReader: got %q, want: %q", got, want)
+	}
+}
+
+func TestXMLReaderFixtures(t *testing.T) {
+	filenames, err := filepath.Glob("./fixtures/*.mrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		var data [][]byte
+		w := NewXMLWriter(&buf)
+		reader := NewReader(file)
+		for {
+			rr, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			record, err := rr.Record()
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if err := w.Write(record); err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			// data before the first delimiter is not written to MARCXML
+			if dropCodeless(record) {
+				b, err := Marshal(record)
+				if err != nil {
+					t.Fatalf("%s: %s", filename, err)
+				}
+				data = append(data, b)
+				continue
+			}
+			data = append(data, rr.Data)
+		}
+		file.Close()
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		xr := NewXMLReader(&buf)
+		for i := 0; ; i++ {
+			record, err := xr.Next()
+			if err == io.EOF {
+				if i != len(data) {
+					t.Errorf("%s: got %d records, want: %d", filename, i, len(data))
+				}
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			b, err := Marshal(record)
+			if err != nil {
+				t.Fatalf("%s: %s", filename, err)
+			}
+			if !bytes.Equal(b, data[i]) {
+				t.Errorf("%s: record %d => %q, want: %q", filename, i, b, data[i])
+			}
+		}
+	}
+}
+
+// dropCodeless removes subfields without code and reports, whether there were
+// any
+func dropCodeless(record *marc22.Record) bool {
+	var dropped bool
+	for i, field := range record.DataFields {
+		if len(field.SubFields) > 0 && field.SubFields[0].Code == "" {
+			record.DataFields[i].SubFields = field.SubFields[1:]
+			dropped = true
+		}
+	}
+	return dropped
+}